@@ -1,14 +1,22 @@
 package main
 
 import (
+	"bot_agent/gateway/internal/cache"
 	"bot_agent/gateway/internal/config"
+	"bot_agent/gateway/internal/httpgateway"
 	"bot_agent/gateway/internal/llmproxy"
 	"bot_agent/gateway/internal/logger"
+	"bot_agent/gateway/internal/observability"
 	"bot_agent/gateway/internal/pb"
+	"bot_agent/gateway/internal/quota"
 	"bot_agent/gateway/internal/storage"
+	"context"
 	"net"
+	"net/http"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 )
@@ -35,32 +43,73 @@ func main() {
 	}
 	defer logger.Close()
 
+	// 初始化 OpenTelemetry Tracer：otlp_endpoint 为空时只设置 W3C 传播器，不导出 trace
+	obsCfg := observability.Config{
+		OTLPEndpoint: config.Get(cfg, "observability.otlp_endpoint", ""),
+		ServiceName:  config.Get(cfg, "observability.service_name", "bot_agent-gateway"),
+		SampleRatio:  config.GetFloat(cfg, "observability.sample_ratio", 1.0),
+	}
+	shutdownTracer, err := observability.InitTracer(context.Background(), obsCfg)
+	if err != nil {
+		logger.Fatal("Failed to init tracer: %v", err)
+	}
+	defer func() {
+		if err := shutdownTracer(context.Background()); err != nil {
+			logger.Warn("Tracer shutdown error: %v", err)
+		}
+	}()
+
 	// 创建存储服务
 	storageService := storage.NewStorageService()
 	defer storageService.Close()
 
 	// 初始化 MySQL 客户端
+	var mysqlClient *storage.MySQLClient
 	mysqlDSN := config.Get(cfg, "mysql.dsn", "")
 	if mysqlDSN != "" {
 		logger.Info("Initializing MySQL client, DSN: %s", mysqlDSN)
-		mysqlClient, err := storage.NewMySQLClient(mysqlDSN)
+		var err error
+		mysqlClient, err = storage.NewMySQLClientWithConfig(storage.MySQLConfig{
+			DSN:             mysqlDSN,
+			MaxOpenConns:    config.GetInt(cfg, "mysql.max_open_conns", 0),
+			MaxIdleConns:    config.GetInt(cfg, "mysql.max_idle_conns", 0),
+			ConnMaxLifetime: time.Duration(config.GetInt(cfg, "mysql.conn_max_lifetime_seconds", 0)) * time.Second,
+			ConnMaxIdleTime: time.Duration(config.GetInt(cfg, "mysql.conn_max_idle_time_seconds", 0)) * time.Second,
+			PingTimeout:     time.Duration(config.GetInt(cfg, "mysql.ping_timeout_seconds", 5)) * time.Second,
+			PingRetries:     config.GetInt(cfg, "mysql.ping_retries", 0),
+		}, &storage.MySQLClientOptions{
+			AllowRawSQL:   config.GetBool(cfg, "mysql.allow_raw_sql", false),
+			StmtCacheSize: config.GetInt(cfg, "mysql.stmt_cache_size", 0),
+			StmtCacheTTL:  time.Duration(config.GetInt(cfg, "mysql.stmt_cache_ttl_seconds", 0)) * time.Second,
+		})
 		if err != nil {
 			logger.Fatal("Failed to create MySQL client: %v", err)
 		}
 		storageService.SetMySQLClient(mysqlClient)
+		if healthInterval := config.GetInt(cfg, "mysql.health_check_interval_seconds", 0); healthInterval > 0 {
+			mysqlClient.HealthCheck(time.Duration(healthInterval) * time.Second)
+		}
 		logger.Info("MySQL client initialized successfully")
 	} else {
 		logger.Warn("MySQL DSN not configured, MySQL storage disabled")
 	}
 
 	// 初始化 Milvus 客户端
+	var milvusClient *storage.MilvusClient
 	milvusAddr := config.Get(cfg, "milvus.addr", "")
 	if milvusAddr != "" {
 		milvusDimension := config.GetInt(cfg, "milvus.dimension", 1536)
 		milvusCollection := config.Get(cfg, "milvus.collection", "memory_vectors")
 		logger.Info("Initializing Milvus client, addr: %s, dimension: %d, collection: %s", milvusAddr, milvusDimension, milvusCollection)
 
-		milvusClient, err := storage.NewMilvusClient(milvusAddr, milvusDimension, milvusCollection)
+		// 声明了 milvus.collection_spec 时，Collection 不存在会按 spec 自动创建 schema、建索引并加载；
+		// 未声明则维持旧行为，Collection 必须提前创建好
+		collectionSpec, err := storage.LoadCollectionSpecFromConfig(cfg)
+		if err != nil {
+			logger.Fatal("Failed to load milvus.collection_spec: %v", err)
+		}
+
+		milvusClient, err = storage.NewMilvusClient(milvusAddr, milvusDimension, milvusCollection, collectionSpec, cfg)
 		if err != nil {
 			logger.Fatal("Failed to create Milvus client: %v", err)
 		}
@@ -70,6 +119,19 @@ func main() {
 		logger.Warn("Milvus addr not configured, Milvus storage disabled")
 	}
 
+	// LLM 用量指标/预算：pricing 表从 metrics.pricing 读取，Register 到全局默认 Registry，
+	// 这样就直接出现在下面已有的 /metrics 端点里，不需要单独再开一个端口
+	pricing, err := llmproxy.LoadPricingFromConfig(cfg)
+	if err != nil {
+		logger.Fatal("Failed to load metrics.pricing: %v", err)
+	}
+	llmMetrics := llmproxy.NewMetrics(pricing)
+	prometheus.MustRegister(llmMetrics)
+	llmBudget := llmproxy.NewBudget(
+		config.GetFloat(cfg, "budget.daily_usd_limit", 0),
+		int64(config.GetInt(cfg, "budget.daily_token_limit", 0)),
+	)
+
 	// 初始化 LLM 代理服务
 	llmEndpoint := config.Get(cfg, "llm.endpoint", "")
 	llmAPIKey := config.Get(cfg, "llm.api_key", "")
@@ -84,19 +146,137 @@ func main() {
 			APIKey:     llmAPIKey,
 			Timeout:    time.Duration(llmTimeout) * time.Second,
 			MaxRetries: llmMaxRetries,
+			Model:      config.Get(cfg, "llm.model", ""),
+			Metrics:    llmMetrics,
+			Budget:     llmBudget,
 		}
 		llmService = llmproxy.NewLLMProxyService(llmConfig)
 		logger.Info("LLM Proxy service initialized successfully")
 	} else {
 		logger.Warn("LLM endpoint or api_key not configured, LLM Proxy disabled")
 	}
+
+	// Provider 层语义缓存（llm.cache_store.*）：kind=memory 用进程内 Map，kind=redis 接 Redis，
+	// 两者都可以被多个部署共享；只有 cache_enabled: true 的部署才会被 CachingClient 包一层，
+	// 区别于上面 cache.collection 配置的那个基于 Milvus 的旧缓存（SetCache，作用在更上层）
+	var llmCacheStore llmproxy.CacheStore
+	switch kind := config.Get(cfg, "llm.cache_store.kind", ""); kind {
+	case "":
+		// 未配置，禁用
+	case "memory":
+		llmCacheStore = llmproxy.NewMemoryCacheStore()
+	case "redis":
+		redisAddr := config.Get(cfg, "llm.cache_store.redis_addr", "")
+		if redisAddr == "" {
+			logger.Warn("llm.cache_store.kind is redis but redis_addr is empty, cache disabled")
+			break
+		}
+		redisClient := redis.NewClient(&redis.Options{
+			Addr:     redisAddr,
+			Password: config.Get(cfg, "llm.cache_store.redis_password", ""),
+			DB:       config.GetInt(cfg, "llm.cache_store.redis_db", 0),
+		})
+		llmCacheStore = llmproxy.NewRedisCacheStore(redisClient, config.Get(cfg, "llm.cache_store.redis_prefix", "llmcache"))
+	default:
+		logger.Warn("unknown llm.cache_store.kind %q, cache disabled", kind)
+	}
+	llmCacheMetrics := llmproxy.NewCacheMetrics()
+	if llmCacheStore != nil {
+		prometheus.MustRegister(llmCacheMetrics)
+	}
+
+	// 加载多厂商部署表（llm.deployments），配置了就启用路由/加权轮询/故障转移，否则维持单一端点直连
+	if deployments, err := llmproxy.LoadDeploymentsFromConfig(cfg); err != nil {
+		logger.Warn("Failed to load llm.deployments, router disabled: %v", err)
+	} else if len(deployments) > 0 {
+		for i := range deployments {
+			deployments[i].Metrics = llmMetrics
+			deployments[i].Budget = llmBudget
+			if llmCacheStore != nil && deployments[i].CacheEnabled {
+				deployments[i].CacheStore = llmCacheStore
+				deployments[i].CacheMetrics = llmCacheMetrics
+			}
+		}
+		router, err := llmproxy.NewRouter(deployments)
+		if err != nil {
+			logger.Fatal("Failed to build LLM router: %v", err)
+		}
+		if llmService == nil {
+			llmService = llmproxy.NewLLMProxyServiceWithRouter(router)
+		} else {
+			llmService.SetRouter(router)
+		}
+		logger.Info("LLM router initialized with %d deployments", len(deployments))
+	}
+
+	// 配额/计费：默认限额从 quota.* 配置读取，价目表复用和 Metrics 相同的 metrics.pricing 配置块，
+	// 这样月度预算核算用的单价和 Metrics 上报的成本不会出自两份互相漂移的配置
+	var quotaMgr *quota.Manager
+	if llmService != nil && mysqlClient != nil {
+		quotaPrices, err := quota.LoadPricesFromConfig(cfg)
+		if err != nil {
+			logger.Fatal("Failed to load metrics.pricing for quota manager: %v", err)
+		}
+		defaultLimits := quota.Limits{
+			RPM:              config.GetInt(cfg, "quota.default_rpm", 0),
+			TPM:              config.GetInt(cfg, "quota.default_tpm", 0),
+			MonthlyBudgetUSD: float64(config.GetInt(cfg, "quota.default_monthly_budget_usd", 0)),
+		}
+		quotaMgr = quota.NewManager(defaultLimits, quotaPrices, mysqlClient, time.Minute)
+		llmService.SetQuotaManager(quotaMgr)
+		logger.Info("Quota manager initialized: rpm=%d tpm=%d monthly_budget_usd=%.2f", defaultLimits.RPM, defaultLimits.TPM, defaultLimits.MonthlyBudgetUSD)
+	}
+	defer func() {
+		if quotaMgr != nil {
+			quotaMgr.Close()
+		}
+	}()
+
+	// 语义缓存：复用 Milvus 向量库，按 Prompt 相似度缓存 ChatCompletion 响应，减少重复调用上游的开销
+	cacheCollection := config.Get(cfg, "cache.collection", "")
+	if cacheCollection != "" && milvusClient != nil && llmService != nil {
+		embedDeploymentID := config.Get(cfg, "cache.embedding_deployment_id", "")
+		// 相似度阈值按千分位配置，避免 YAML 中浮点数解析带来的精度问题
+		thresholdPermille := config.GetInt(cfg, "cache.similarity_threshold_permille", 950)
+		ttlSeconds := config.GetInt(cfg, "cache.ttl_seconds", 3600)
+
+		threshold := float32(thresholdPermille) / 1000
+		embedFunc := func(ctx context.Context, texts []string) ([]float32, error) {
+			return llmService.Embed(ctx, embedDeploymentID, texts)
+		}
+		semanticCache := cache.New(milvusClient, cacheCollection, embedFunc, threshold, time.Duration(ttlSeconds)*time.Second)
+		llmService.SetCache(semanticCache)
+		logger.Info("Semantic cache initialized: collection=%s threshold=%.3f ttl=%ds", cacheCollection, threshold, ttlSeconds)
+	}
+
 	defer func() {
 		if llmService != nil {
 			llmService.Close()
 		}
 	}()
 
-	grpcServer := grpc.NewServer()
+	// OpenAI 兼容的 HTTP/SSE 网关：让浏览器和现成的 OpenAI SDK 直接访问 /v1/chat/completions，无需接入 gRPC
+	if llmService != nil {
+		httpGatewayAddr := config.Get(cfg, "http_gateway.addr", ":8081")
+		gatewayCfg := httpgateway.Config{
+			HeartbeatInterval: time.Duration(config.GetInt(cfg, "http_gateway.heartbeat_interval_seconds", 15)) * time.Second,
+			BufferSize:        config.GetInt(cfg, "http_gateway.buffer_size", 32),
+		}
+		gw := httpgateway.NewGateway(llmService, gatewayCfg)
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/v1/chat/completions", gw)
+			logger.Info("HTTP/SSE gateway listening on %s", httpGatewayAddr)
+			if err := http.ListenAndServe(httpGatewayAddr, mux); err != nil {
+				logger.Error("HTTP/SSE gateway stopped: %v", err)
+			}
+		}()
+	}
+
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(observability.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(observability.StreamServerInterceptor()),
+	)
 	pb.RegisterStorageServiceServer(grpcServer, storageService)
 	// 注册 LLM 代理服务
 	if llmService != nil {
@@ -105,6 +285,17 @@ func main() {
 	}
 	reflection.Register(grpcServer)
 
+	// 独立的 /metrics HTTP 监听端口，供 Prometheus 抓取
+	metricsAddr := config.Get(cfg, "observability.metrics_addr", ":9090")
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", observability.MetricsHandler())
+		logger.Info("Metrics server listening on %s", metricsAddr)
+		if err := http.ListenAndServe(metricsAddr, mux); err != nil {
+			logger.Error("Metrics server stopped: %v", err)
+		}
+	}()
+
 	addr := config.Get(cfg, "server.addr", ":50051")
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
@@ -0,0 +1,223 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"bot_agent/gateway/internal/logger"
+	"bot_agent/gateway/internal/pb"
+	"bot_agent/gateway/internal/storage"
+)
+
+// Policy 对应 pb.ChatCompletionRequest.CachePolicy，控制单次请求如何使用语义缓存
+type Policy string
+
+const (
+	PolicyBypass    Policy = "bypass"     // 既不读也不写缓存
+	PolicyReadOnly  Policy = "read_only"  // 只读缓存，miss 时正常调用上游但不写入新缓存
+	PolicyReadWrite Policy = "read_write" // 默认行为：读缓存，miss 后异步写入
+)
+
+// ParsePolicy 解析缓存策略字符串，空值或未知值按 read_write 处理（与现有无缓存行为兼容）
+func ParsePolicy(s string) Policy {
+	switch Policy(s) {
+	case PolicyBypass, PolicyReadOnly, PolicyReadWrite:
+		return Policy(s)
+	default:
+		return PolicyReadWrite
+	}
+}
+
+// EmbedFunc 由调用方（llmproxy）注入，用于把文本转换为向量；Cache 本身不关心走哪个 Provider/部署
+type EmbedFunc func(ctx context.Context, texts []string) ([]float32, error)
+
+// payload 字段名，对应 Milvus collection 里除 id/vector 外新增的标量列
+const (
+	fieldResponseJSON = "response_json"
+	fieldDeploymentID = "deployment_id"
+	fieldToolsHash    = "tools_hash"
+	fieldExpiresAt    = "expires_at"
+)
+
+// Metrics 是缓存命中情况的简单计数器，供指标上报（如 Prometheus）读取
+type Metrics struct {
+	hits   int64
+	misses int64
+}
+
+// Hits 返回累计命中次数
+func (m *Metrics) Hits() int64 { return atomic.LoadInt64(&m.hits) }
+
+// Misses 返回累计未命中次数
+func (m *Metrics) Misses() int64 { return atomic.LoadInt64(&m.misses) }
+
+// Cache 是基于语义向量相似度的 Prompt/Response 缓存
+// 命中判定：对拼接后的用户消息做 Embedding，在 Milvus 中检索 top-1，相似度超过阈值且 deployment_id/tools_hash 匹配则命中
+type Cache struct {
+	milvus     *storage.MilvusClient
+	collection string
+	embed      EmbedFunc
+	threshold  float32
+	ttl        time.Duration
+	metrics    Metrics
+}
+
+// New 创建语义缓存，collection 复用现有 Milvus 向量维度配置，threshold 为余弦相似度阈值（如 0.95）
+func New(milvus *storage.MilvusClient, collection string, embed EmbedFunc, threshold float32, ttl time.Duration) *Cache {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &Cache{
+		milvus:     milvus,
+		collection: collection,
+		embed:      embed,
+		threshold:  threshold,
+		ttl:        ttl,
+	}
+}
+
+// Metrics 返回缓存命中率指标
+func (c *Cache) Metrics() *Metrics { return &c.metrics }
+
+// toolsHash 对工具定义做哈希，避免携带不同工具定义的请求复用彼此的缓存响应
+func toolsHash(tools interface{}) string {
+	if tools == nil {
+		return ""
+	}
+	data, err := json.Marshal(tools)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ToolsHash 导出版本，供 llmproxy 拼装请求时调用
+func ToolsHash(tools interface{}) string { return toolsHash(tools) }
+
+// cacheKey 作为 Milvus 中该条向量的主键，由 prompt 文本 + deployment + tools hash 共同决定
+func cacheKey(promptText, deploymentID, toolsHash string) string {
+	sum := sha256.Sum256([]byte(promptText + "|" + deploymentID + "|" + toolsHash))
+	return hex.EncodeToString(sum[:])
+}
+
+// Lookup 查询语义缓存，命中返回序列化的响应 JSON
+func (c *Cache) Lookup(ctx context.Context, promptText, deploymentID, toolsHash string) (string, bool) {
+	if c == nil || c.milvus == nil || c.embed == nil {
+		return "", false
+	}
+
+	vector, err := c.embed(ctx, []string{promptText})
+	if err != nil {
+		logger.Warn("cache lookup embedding failed: %v", err)
+		return "", false
+	}
+
+	ops := []*pb.VectorOperation{
+		{
+			Collection: c.collection,
+			Operation: &pb.VectorOperation_Search{
+				Search: &pb.VectorSearchOp{
+					QueryVector: vector,
+					TopK:        1,
+					MinScore:    c.threshold,
+					Filter: map[string]*pb.TypedValue{
+						fieldDeploymentID: {Value: &pb.TypedValue_StringValue{StringValue: deploymentID}},
+						fieldToolsHash:    {Value: &pb.TypedValue_StringValue{StringValue: toolsHash}},
+					},
+					OutputFields: []string{fieldResponseJSON, fieldExpiresAt},
+				},
+			},
+		},
+	}
+
+	results, err := c.milvus.ExecuteBatch(ctx, ops)
+	if err != nil || len(results) == 0 || !results[0].Success {
+		atomic.AddInt64(&c.metrics.misses, 1)
+		return "", false
+	}
+
+	searchResult := results[0].GetSearchResult()
+	if searchResult == nil || len(searchResult.Matches) == 0 {
+		atomic.AddInt64(&c.metrics.misses, 1)
+		return "", false
+	}
+
+	match := searchResult.Matches[0]
+
+	if expiresTV, ok := match.Metadata[fieldExpiresAt]; ok {
+		if ts, ok := expiresTV.GetValue().(*pb.TypedValue_TimestampValue); ok {
+			if ts.TimestampValue > 0 && time.Now().UnixMilli() > ts.TimestampValue {
+				atomic.AddInt64(&c.metrics.misses, 1)
+				return "", false
+			}
+		}
+	}
+
+	responseTV, ok := match.Metadata[fieldResponseJSON]
+	if !ok {
+		atomic.AddInt64(&c.metrics.misses, 1)
+		return "", false
+	}
+	responseStr, ok := responseTV.GetValue().(*pb.TypedValue_StringValue)
+	if !ok {
+		atomic.AddInt64(&c.metrics.misses, 1)
+		return "", false
+	}
+
+	atomic.AddInt64(&c.metrics.hits, 1)
+	return responseStr.StringValue, true
+}
+
+// Store 异步把一次上游响应写入语义缓存，不阻塞调用方返回结果给客户端
+func (c *Cache) Store(promptText, deploymentID, toolsHash, responseJSON string) {
+	if c == nil || c.milvus == nil || c.embed == nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		vector, err := c.embed(ctx, []string{promptText})
+		if err != nil {
+			logger.Warn("cache store embedding failed: %v", err)
+			return
+		}
+
+		id := cacheKey(promptText, deploymentID, toolsHash)
+		expiresAt := time.Now().Add(c.ttl).UnixMilli()
+
+		ops := []*pb.VectorOperation{
+			{
+				Collection: c.collection,
+				Operation: &pb.VectorOperation_Upsert{
+					Upsert: &pb.VectorUpsertOp{
+						Vectors: []*pb.VectorData{
+							{
+								Id:     id,
+								Vector: vector,
+								Metadata: map[string]*pb.TypedValue{
+									fieldResponseJSON: {Value: &pb.TypedValue_StringValue{StringValue: responseJSON}},
+									fieldDeploymentID: {Value: &pb.TypedValue_StringValue{StringValue: deploymentID}},
+									fieldToolsHash:    {Value: &pb.TypedValue_StringValue{StringValue: toolsHash}},
+									fieldExpiresAt:    {Value: &pb.TypedValue_TimestampValue{TimestampValue: expiresAt}},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		results, err := c.milvus.ExecuteBatch(ctx, ops)
+		if err != nil || len(results) == 0 || !results[0].Success {
+			logger.Warn("cache store upsert failed: %v, results: %v", err, results)
+			return
+		}
+	}()
+}
@@ -118,6 +118,52 @@ func GetBool(cfg map[string]interface{}, key string, defaultVal bool) bool {
 	return defaultVal
 }
 
+// GetFloat 从配置中获取浮点数值，如采样率、相似度阈值等
+func GetFloat(cfg map[string]interface{}, key string, defaultVal float64) float64 {
+	envKey := strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+	if val := os.Getenv(envKey); val != "" {
+		var f float64
+		fmt.Sscanf(val, "%f", &f)
+		return f
+	}
+
+	val := getNestedValue(cfg, key)
+	if val == nil {
+		return defaultVal
+	}
+	switch v := val.(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	}
+	return defaultVal
+}
+
+// GetIn 和 Get 类似，但额外接受若干动态 path 片段（如 collection 名）拼在 key 之后再查找，
+// 用于 map-of-map 场景（如 milvus.index_profiles.<collection>.metric_type），避免调用方自己
+// 用 "." 拼接动态片段时被误当成新的路径分隔符
+func GetIn(cfg map[string]interface{}, key string, segments []string, defaultVal string) string {
+	return Get(cfg, joinPath(key, segments), defaultVal)
+}
+
+// GetIntIn 是 GetIn 的整数版本，用法同 GetIn
+func GetIntIn(cfg map[string]interface{}, key string, segments []string, defaultVal int) int {
+	return GetInt(cfg, joinPath(key, segments), defaultVal)
+}
+
+// joinPath 把 key 和动态 segments 拼接成点号分隔的完整路径
+func joinPath(key string, segments []string) string {
+	parts := append([]string{key}, segments...)
+	return strings.Join(parts, ".")
+}
+
+// GetRaw 获取配置中指定路径的原始值（未做类型转换），用于读取列表/对象等复杂结构
+// 与 Get/GetInt/GetBool 不同，GetRaw 不支持环境变量覆盖
+func GetRaw(cfg map[string]interface{}, key string) interface{} {
+	return getNestedValue(cfg, key)
+}
+
 // getNestedValue 获取嵌套的配置值
 func getNestedValue(cfg map[string]interface{}, key string) interface{} {
 	keys := strings.Split(key, ".")
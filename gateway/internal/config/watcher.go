@@ -0,0 +1,227 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"bot_agent/gateway/internal/logger"
+)
+
+// Watcher 包装 Load/LoadWithDefault，在配置文件变化时原子地重新解析并通知订阅者，
+// 让调用方可以按路径订阅自己关心的配置项（如 milvus.index_profiles、logger.level），
+// 不需要重启进程即可生效。环境变量覆盖在重载后依然优先生效，因为重载走的还是
+// Get/GetInt/GetBool 这些帮助函数
+type Watcher struct {
+	path     string
+	debounce time.Duration
+
+	mu  sync.RWMutex
+	cfg map[string]interface{} // 当前快照，读写都要经过 mu，调用方永远看不到写到一半的配置
+
+	subsMu sync.Mutex
+	subs   map[string][]func(old, new interface{})
+
+	fsw       *fsnotify.Watcher
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewWatcher 加载 path 指向的 YAML 文件并启动一个 fsnotify goroutine 监听它的变化
+func NewWatcher(path string) (*Watcher, error) {
+	cfg := make(map[string]interface{})
+	if err := Load(path, &cfg); err != nil {
+		return nil, err
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("create fsnotify watcher: %w", err)
+	}
+	// 监听所在目录而不是文件本身：inotify watch 绑定的是 inode，而 vim/大多数编辑器的“安全保存”、
+	// Kubernetes ConfigMap 挂载都是“写临时文件再 rename 覆盖”，这会换掉 path 的 inode——监听文件本身
+	// 只会收到一个被下面 Write|Create 过滤掉的 Rename 事件，之后就再也收不到任何事件。监听目录后在
+	// loop 里按文件名过滤，两种保存方式都能触发重载
+	dir := filepath.Dir(path)
+	if err := fsw.Add(dir); err != nil {
+		fsw.Close()
+		return nil, fmt.Errorf("watch config dir %s: %w", dir, err)
+	}
+
+	w := &Watcher{
+		path:     path,
+		debounce: 500 * time.Millisecond,
+		cfg:      cfg,
+		subs:     make(map[string][]func(old, new interface{})),
+		fsw:      fsw,
+		done:     make(chan struct{}),
+	}
+	go w.loop()
+	return w, nil
+}
+
+// NewWatcherWithDefault 和 LoadWithDefault 一样按顺序尝试多个候选路径，用第一个存在的文件
+// 启动 Watcher，返回实际使用的路径
+func NewWatcherWithDefault(candidates []string) (*Watcher, string, error) {
+	cfg := make(map[string]interface{})
+	path, err := LoadWithDefault(candidates, &cfg)
+	if err != nil {
+		return nil, "", err
+	}
+	w, err := NewWatcher(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return w, path, nil
+}
+
+// Snapshot 返回当前配置快照，RWMutex 保证不会读到重载写到一半的 map
+func (w *Watcher) Snapshot() map[string]interface{} {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.cfg
+}
+
+// Get/GetInt/GetBool/GetFloat 是 Snapshot() 之上的便捷封装，用法和包级同名函数一致
+
+func (w *Watcher) Get(key string, defaultVal string) string {
+	return Get(w.Snapshot(), key, defaultVal)
+}
+
+func (w *Watcher) GetInt(key string, defaultVal int) int {
+	return GetInt(w.Snapshot(), key, defaultVal)
+}
+
+func (w *Watcher) GetBool(key string, defaultVal bool) bool {
+	return GetBool(w.Snapshot(), key, defaultVal)
+}
+
+func (w *Watcher) GetFloat(key string, defaultVal float64) float64 {
+	return GetFloat(w.Snapshot(), key, defaultVal)
+}
+
+// OnChange 订阅一个点号分隔的配置路径（如 "logger.level"），每次重载后该路径下的值发生变化
+// 就会异步回调 cb(old, new)；值未变化不会触发。key 为空字符串时每次重载都会回调一次（不比较新旧值），
+// old/new 为整个配置 map
+func (w *Watcher) OnChange(key string, cb func(old, new interface{})) {
+	w.subsMu.Lock()
+	defer w.subsMu.Unlock()
+	w.subs[key] = append(w.subs[key], cb)
+}
+
+// Stop 停止 fsnotify 监听并释放底层文件句柄，可以安全地多次调用
+func (w *Watcher) Stop() {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		w.fsw.Close()
+	})
+}
+
+// loop 消费 fsnotify 事件，WRITE/CREATE 触发重载；很多编辑器保存文件时会连续产生好几个事件
+// （truncate + write，或者 rename 回来），所以用一个 debounce 定时器把短时间内的多次事件合并成
+// 一次重载。因为监听的是目录，事件可能来自目录下的任意文件，需要先按文件名过滤，只关心 path 本身
+func (w *Watcher) loop() {
+	name := filepath.Base(w.path)
+	var debounceTimer *time.Timer
+	for {
+		select {
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			if filepath.Base(event.Name) != name {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			if debounceTimer != nil {
+				debounceTimer.Stop()
+			}
+			debounceTimer = time.AfterFunc(w.debounce, w.reload)
+
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			logger.Warn("config watcher error on %s: %v", w.path, err)
+
+		case <-w.done:
+			if debounceTimer != nil {
+				debounceTimer.Stop()
+			}
+			return
+		}
+	}
+}
+
+// reload 重新解析 YAML 并原子地替换快照，再按订阅的 key 逐个比较新旧值，发生变化的才回调
+func (w *Watcher) reload() {
+	next := make(map[string]interface{})
+	if err := Load(w.path, &next); err != nil {
+		logger.Warn("config watcher: reload %s failed, keeping previous snapshot: %v", w.path, err)
+		return
+	}
+
+	w.mu.Lock()
+	prev := w.cfg
+	w.cfg = next
+	w.mu.Unlock()
+
+	w.dispatch(prev, next)
+}
+
+// dispatch 对每个订阅的 key 比较重载前后的值，不同就回调；"" 订阅在任意重载后都会触发一次，
+// old/new 传整个配置 map
+func (w *Watcher) dispatch(prev, next map[string]interface{}) {
+	w.subsMu.Lock()
+	subs := make(map[string][]func(old, new interface{}), len(w.subs))
+	for k, cbs := range w.subs {
+		subs[k] = append([]func(old, new interface{}){}, cbs...)
+	}
+	w.subsMu.Unlock()
+
+	for key, cbs := range subs {
+		var oldVal, newVal interface{}
+		if key == "" {
+			oldVal, newVal = prev, next
+		} else {
+			oldVal = getNestedValue(prev, key)
+			newVal = getNestedValue(next, key)
+			if valuesEqual(oldVal, newVal) {
+				continue
+			}
+		}
+		for _, cb := range cbs {
+			cb(oldVal, newVal)
+		}
+	}
+}
+
+// valuesEqual 比较两个从 YAML 解出来的值是否相等，覆盖 Get/GetInt/GetBool/GetFloat 支持的
+// 标量类型；解析不出来的复杂类型（列表、嵌套 map）一律当作不相等处理，交给订阅者自己判断
+func valuesEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	as, aok := scalarString(a)
+	bs, bok := scalarString(b)
+	if aok && bok {
+		return as == bs
+	}
+	return false
+}
+
+// scalarString 把 Get 系列帮助函数支持的标量类型格式化为可比较的字符串
+func scalarString(v interface{}) (string, bool) {
+	switch v.(type) {
+	case string, int, int64, float64, bool:
+		return strings.TrimSpace(fmt.Sprintf("%v", v)), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,132 @@
+package httpgateway
+
+import (
+	"context"
+	"sync"
+
+	"bot_agent/gateway/internal/pb"
+)
+
+// subscriber 是 inFlightStream 里的一路订阅
+// closed 在订阅者退出（客户端断开）时关闭，让 broadcast 不再阻塞在它的 ch 上
+type subscriber struct {
+	ch     chan *pb.ChatCompletionChunk
+	closed chan struct{}
+}
+
+// inFlightStream 代表一次仍在进行中的上游流式调用，可能被多个 HTTP 请求共享
+// 只有发起它的那个请求（leader）真正驱动上游调用并 broadcast，其余请求只读取共享的 chunk
+//
+// Ctx 是驱动上游调用要用的 context，它和任何一个订阅者（包括 leader 自己）的 HTTP 请求 context
+// 都是分离的：leader 先行断开不应该打断其它仍在读取同一份广播的订阅者。只有当最后一个订阅者也
+// 退出、且上游调用还没结束时，才会通过 cancel 主动收工，避免所有客户端都走掉之后上游调用空跑
+type inFlightStream struct {
+	mu   sync.Mutex
+	subs map[int]*subscriber
+	next int
+	err  error
+	done bool // 上游调用是否已经结束（finish 已被调用）
+
+	Ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newInFlightStream() *inFlightStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &inFlightStream{subs: make(map[int]*subscriber), Ctx: ctx, cancel: cancel}
+}
+
+// subscribe 注册一个新的订阅者，bufferSize 是它的 chunk 缓冲区大小：
+// 缓冲区写满后 broadcast 会阻塞，从而把这个慢客户端的背压传导回上游拉取循环
+func (s *inFlightStream) subscribe(bufferSize int) (int, <-chan *pb.ChatCompletionChunk) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.next
+	s.next++
+	sub := &subscriber{
+		ch:     make(chan *pb.ChatCompletionChunk, bufferSize),
+		closed: make(chan struct{}),
+	}
+	s.subs[id] = sub
+	return id, sub.ch
+}
+
+// unsubscribe 供提前断开或慢到被放弃的客户端退出广播，避免继续拖慢其它订阅者
+// 退出后如果这是最后一个订阅者（包括 leader 自己）、且上游调用还没结束，就取消 Ctx 提前收工
+func (s *inFlightStream) unsubscribe(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sub, ok := s.subs[id]; ok {
+		close(sub.closed)
+		delete(s.subs, id)
+	}
+	if len(s.subs) == 0 && !s.done {
+		s.cancel()
+	}
+}
+
+// broadcast 把一个 chunk 发送给当前所有订阅者
+// 任意一个仍然存活的订阅者缓冲区写满，都会阻塞在这里——这正是背压要传导给上游的地方
+func (s *inFlightStream) broadcast(chunk *pb.ChatCompletionChunk) {
+	s.mu.Lock()
+	subs := make([]*subscriber, 0, len(s.subs))
+	for _, sub := range s.subs {
+		subs = append(subs, sub)
+	}
+	s.mu.Unlock()
+
+	for _, sub := range subs {
+		select {
+		case sub.ch <- chunk:
+		case <-sub.closed:
+		}
+	}
+}
+
+// finish 标记上游流已经结束（成功或失败），关闭所有仍存活订阅者的 channel
+// 此时 leader 的上游调用已经返回，不会再有并发的 broadcast，关闭 channel 是安全的
+func (s *inFlightStream) finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+	s.done = true
+	s.cancel() // 上游调用已经返回，释放 Ctx；早于这一刻的 unsubscribe 可能已经调用过，cancel 本身是幂等的
+	for _, sub := range s.subs {
+		close(sub.ch)
+	}
+}
+
+// broker 按请求指纹把并发的相同流式请求合并为一次上游调用，其余订阅者共享同一份 chunk 广播
+type broker struct {
+	mu      sync.Mutex
+	streams map[string]*inFlightStream
+}
+
+func newBroker() *broker {
+	return &broker{streams: make(map[string]*inFlightStream)}
+}
+
+// joinOrStart 按 fingerprint 查找是否已有进行中的上游流；没有则新建一个并让调用方成为 leader
+func (b *broker) joinOrStart(fingerprint string, bufferSize int) (stream *inFlightStream, subID int, ch <-chan *pb.ChatCompletionChunk, isLeader bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if existing, ok := b.streams[fingerprint]; ok {
+		subID, ch = existing.subscribe(bufferSize)
+		return existing, subID, ch, false
+	}
+
+	stream = newInFlightStream()
+	b.streams[fingerprint] = stream
+	subID, ch = stream.subscribe(bufferSize)
+	return stream, subID, ch, true
+}
+
+// release 由 leader 在上游调用结束后调用，把自己从 broker 里摘除，后续同指纹的请求会重新发起上游调用
+func (b *broker) release(fingerprint string, stream *inFlightStream) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.streams[fingerprint] == stream {
+		delete(b.streams, fingerprint)
+	}
+}
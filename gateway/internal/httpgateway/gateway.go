@@ -0,0 +1,257 @@
+// Package httpgateway 把 LLMProxyService 的流式对话能力包装成 OpenAI 兼容的 HTTP/SSE 接口，
+// 使浏览器和现成的 OpenAI SDK 可以直接访问 /v1/chat/completions，而不需要接入 gRPC
+package httpgateway
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"bot_agent/gateway/internal/llmproxy"
+	"bot_agent/gateway/internal/logger"
+	"bot_agent/gateway/internal/pb"
+)
+
+// Config 网关行为配置
+type Config struct {
+	// HeartbeatInterval 心跳注释的发送间隔，避免反向代理因为长时间没有数据而关闭空闲的 SSE 连接；默认 15s
+	HeartbeatInterval time.Duration
+	// BufferSize 每个订阅者的 chunk 缓冲区大小；写满后 broadcast 阻塞，把背压传导回上游拉取循环；默认 32
+	BufferSize int
+}
+
+// setDefaults 填充未配置的字段
+func (c *Config) setDefaults() {
+	if c.HeartbeatInterval <= 0 {
+		c.HeartbeatInterval = 15 * time.Second
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = 32
+	}
+}
+
+// Gateway 是 /v1/chat/completions 的 HTTP/SSE 前端，内部复用 LLMProxyService 已有的
+// 缓存、配额、多厂商路由逻辑，只负责协议转换、连接管理和多订阅者合并
+type Gateway struct {
+	llm    *llmproxy.LLMProxyService
+	cfg    Config
+	broker *broker
+}
+
+// NewGateway 创建 HTTP/SSE 网关
+func NewGateway(llm *llmproxy.LLMProxyService, cfg Config) *Gateway {
+	cfg.setDefaults()
+	return &Gateway{
+		llm:    llm,
+		cfg:    cfg,
+		broker: newBroker(),
+	}
+}
+
+// chatCompletionRequestBody 是 OpenAI 兼容请求体：除了 model 字段外，
+// 其余字段和 llmproxy.ChatCompletionHTTPRequest 的 JSON 布局完全一致，直接内嵌复用
+type chatCompletionRequestBody struct {
+	Model string `json:"model"`
+	llmproxy.ChatCompletionHTTPRequest
+}
+
+// ServeHTTP 实现 http.Handler，处理 /v1/chat/completions
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if g.llm == nil {
+		http.Error(w, "LLM proxy not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	var body chatCompletionRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if body.Model == "" {
+		http.Error(w, "model is required", http.StatusBadRequest)
+		return
+	}
+
+	if !body.Stream {
+		g.handleNonStream(w, r, &body)
+		return
+	}
+	g.handleStream(w, r, &body)
+}
+
+// handleNonStream 处理 stream=false 的普通请求，直接复用 LLMProxyService.ChatCompletion
+func (g *Gateway) handleNonStream(w http.ResponseWriter, r *http.Request, body *chatCompletionRequestBody) {
+	pbReq := llmproxy.RequestFromHTTP(&body.ChatCompletionHTTPRequest, body.Model)
+
+	resp, err := g.llm.ChatCompletion(r.Context(), pbReq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	httpResp := llmproxy.ResponseToHTTP(resp)
+
+	w.Header().Set("Content-Type", "application/json")
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		json.NewEncoder(gz).Encode(httpResp)
+		return
+	}
+	json.NewEncoder(w).Encode(httpResp)
+}
+
+// handleStream 处理 stream=true 的 SSE 请求：发起（或加入）一次上游流式调用，
+// 把 pb.ChatCompletionChunk 逐个转换成 OpenAI 兼容的 SSE data 帧下发给客户端
+func (g *Gateway) handleStream(w http.ResponseWriter, r *http.Request, body *chatCompletionRequestBody) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	httpReq := &body.ChatCompletionHTTPRequest
+	fingerprint := requestFingerprint(body.Model, httpReq)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // 告知 Nginx 等反向代理不要缓冲 SSE 响应
+
+	var out io.Writer = w
+	var gz *gzip.Writer
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz = gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	}
+
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	flush := func() {
+		if gz != nil {
+			gz.Flush()
+		}
+		flusher.Flush()
+	}
+
+	// ctx 只管这一个 HTTP 请求自己的生命周期（下面的心跳/转发循环用它判断客户端是否断开），
+	// 不会传给驱动上游调用的 drive：多个订阅者共享同一个上游流时，任何一个订阅者（包括 leader）
+	// 断开都不该连带砍断其它人还在读的广播，上游调用的取消由 stream.Ctx 单独管理，见 broker.go
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	log := logger.WithContext(ctx).With(
+		logger.String("grpc_method", "HTTPChatCompletionStream"),
+		logger.String("deployment_id", body.Model),
+	)
+
+	stream, subID, ch, isLeader := g.joinStream(fingerprint, body.Model, httpReq)
+	// leader 和其它订阅者一样都要在自己的 HTTP 请求结束时退订，避免误判为"还有订阅者"；
+	// 真正驱动上游调用的 ctx 是 stream.Ctx（与任何一个订阅者的请求生命周期分离），见 drive 的调用
+	defer stream.unsubscribe(subID)
+
+	if isLeader {
+		log.Infow("start upstream chat completion stream")
+		go g.drive(stream.Ctx, stream, fingerprint, llmproxy.RequestFromHTTP(httpReq, body.Model), log)
+	} else {
+		log.Infow("joined in-flight chat completion stream")
+	}
+
+	heartbeat := time.NewTicker(g.cfg.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// 客户端主动断开，这里直接返回即可；defer 里的 unsubscribe 会在自己是最后一个
+			// 订阅者时才去取消共享的上游调用，不会影响其它还在读同一份广播的订阅者
+			return
+		case <-heartbeat.C:
+			if _, err := io.WriteString(out, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flush()
+		case chunk, ok := <-ch:
+			if !ok {
+				io.WriteString(out, "data: [DONE]\n\n")
+				flush()
+				return
+			}
+			data, err := json.Marshal(llmproxy.ChunkToHTTP(chunk))
+			if err != nil {
+				log.Warnw("marshal sse chunk failed", "error", err)
+				continue
+			}
+			fmt.Fprintf(out, "data: %s\n\n", data)
+			flush()
+		}
+	}
+}
+
+// joinStream 按请求指纹加入一个已有的上游流，或者以 leader 身份新建一个
+// fingerprint 为空（序列化失败）时总是独立发起一次调用，不参与合并
+func (g *Gateway) joinStream(fingerprint, deploymentID string, httpReq *llmproxy.ChatCompletionHTTPRequest) (*inFlightStream, int, <-chan *pb.ChatCompletionChunk, bool) {
+	if fingerprint == "" {
+		stream := newInFlightStream()
+		subID, ch := stream.subscribe(g.cfg.BufferSize)
+		return stream, subID, ch, true
+	}
+	return g.broker.joinOrStart(fingerprint, g.cfg.BufferSize)
+}
+
+// drive 由 leader 调用：真正驱动一次上游流式调用，把收到的每个 chunk 广播给所有订阅者
+func (g *Gateway) drive(ctx context.Context, stream *inFlightStream, fingerprint string, pbReq *pb.ChatCompletionRequest, log *logger.Logger) {
+	err := g.llm.StreamChatCompletion(ctx, pbReq, func(chunk *pb.ChatCompletionChunk) error {
+		stream.broadcast(chunk)
+		return nil
+	})
+	if fingerprint != "" {
+		g.broker.release(fingerprint, stream)
+	}
+	stream.finish(err)
+	if err != nil && ctx.Err() == nil {
+		log.Errorw("upstream chat completion stream failed", "error", err)
+	}
+}
+
+// requestFingerprint 计算请求指纹，用于识别"同一份正在进行中的流式请求"
+// 只取参与上游调用的字段（去掉 stream/user），序列化失败时返回空字符串，调用方据此放弃合并
+func requestFingerprint(deploymentID string, httpReq *llmproxy.ChatCompletionHTTPRequest) string {
+	normalized := *httpReq
+	normalized.Stream = false
+	normalized.User = ""
+
+	data, err := json.Marshal(struct {
+		DeploymentID string                             `json:"deployment_id"`
+		Req          llmproxy.ChatCompletionHTTPRequest `json:"req"`
+	}{
+		DeploymentID: deploymentID,
+		Req:          normalized,
+	})
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// acceptsGzip 判断客户端是否声明支持 gzip 编码
+func acceptsGzip(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+}
@@ -0,0 +1,210 @@
+package llmproxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AnthropicProvider 适配 Anthropic Messages API，做 OpenAI 风格请求/响应与 Anthropic 格式之间的转换
+type AnthropicProvider struct {
+	name       string
+	model      string
+	endpoint   string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewAnthropicProvider 创建 Anthropic Provider
+func NewAnthropicProvider(name, endpoint, apiKey, model string, timeout time.Duration) *AnthropicProvider {
+	if timeout == 0 {
+		timeout = 60 * time.Second
+	}
+	return &AnthropicProvider{
+		name:       name,
+		model:      model,
+		endpoint:   strings.TrimRight(endpoint, "/"),
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+func (p *AnthropicProvider) Name() string { return p.name }
+
+func (p *AnthropicProvider) Supports(model string) bool { return p.model == "" || p.model == model }
+
+// anthropicMessage 是 Anthropic Messages API 的请求消息格式
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// anthropicRequest Anthropic Messages API 请求体
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	Messages    []anthropicMessage `json:"messages"`
+	System      string             `json:"system,omitempty"`
+	MaxTokens   int32              `json:"max_tokens"`
+	Temperature *float32           `json:"temperature,omitempty"`
+	TopP        *float32           `json:"top_p,omitempty"`
+	Stream      bool               `json:"stream,omitempty"`
+}
+
+// anthropicResponse Anthropic Messages API 响应体
+type anthropicResponse struct {
+	ID         string `json:"id"`
+	Model      string `json:"model"`
+	StopReason string `json:"stop_reason"`
+	Content    []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int32 `json:"input_tokens"`
+		OutputTokens int32 `json:"output_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// toAnthropicRequest 将 OpenAI 风格请求转换为 Anthropic 请求
+// Anthropic 把 system 消息单独作为一个字段，而不是消息数组中的一条
+func (p *AnthropicProvider) toAnthropicRequest(req *ChatCompletionHTTPRequest) *anthropicRequest {
+	out := &anthropicRequest{
+		Model:       p.model,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   2048,
+	}
+	if req.MaxCompletionTokens != nil {
+		out.MaxTokens = *req.MaxCompletionTokens
+	}
+
+	for _, msg := range req.Messages {
+		content, ok := msg.Content.(string)
+		if !ok {
+			content = fmt.Sprintf("%v", msg.Content)
+		}
+		if msg.Role == "system" {
+			if out.System != "" {
+				out.System += "\n"
+			}
+			out.System += content
+			continue
+		}
+		out.Messages = append(out.Messages, anthropicMessage{Role: msg.Role, Content: content})
+	}
+
+	return out
+}
+
+// fromAnthropicResponse 将 Anthropic 响应转换为 OpenAI 风格响应，便于 Router 和调用方统一处理
+func fromAnthropicResponse(resp *anthropicResponse) *ChatCompletionHTTPResponse {
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+
+	return &ChatCompletionHTTPResponse{
+		ID:     resp.ID,
+		Object: "chat.completion",
+		Model:  resp.Model,
+		Choices: []ChoiceHTTP{
+			{
+				Index:        0,
+				Message:      ChatMessageHTTP{Role: "assistant", Content: text.String()},
+				FinishReason: resp.StopReason,
+			},
+		},
+		Usage: UsageHTTP{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		},
+	}
+}
+
+func (p *AnthropicProvider) ChatCompletion(ctx context.Context, req *ChatCompletionHTTPRequest) (*ChatCompletionHTTPResponse, error) {
+	anthReq := p.toAnthropicRequest(req)
+
+	jsonBytes, err := json.Marshal(anthReq)
+	if err != nil {
+		return nil, fmt.Errorf("marshal anthropic request failed: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+"/v1/messages", bytes.NewReader(jsonBytes))
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("anthropic-version", "2023-06-01")
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("do request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response body failed: %w", err)
+	}
+
+	var result anthropicResponse
+	if err := json.Unmarshal(bodyBytes, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal response failed: %w, body: %s", err, string(bodyBytes))
+	}
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("anthropic API error: %s (type: %s)", result.Error.Message, result.Error.Type)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	return fromAnthropicResponse(&result), nil
+}
+
+// ChatCompletionStream Anthropic 的 SSE 事件格式（message_start/content_block_delta/...）与 OpenAI 不同
+// 当前按非流式调用后一次性回放为单个 chunk，后续如需逐字 TTFT 优化可再接入 Anthropic 原生流事件解析
+func (p *AnthropicProvider) ChatCompletionStream(ctx context.Context, req *ChatCompletionHTTPRequest, handler StreamChunkHandler) error {
+	resp, err := p.ChatCompletion(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	for _, choice := range resp.Choices {
+		content, _ := choice.Message.Content.(string)
+		if err := handler(&ChatCompletionChunkHTTP{
+			ID:     resp.ID,
+			Object: "chat.completion.chunk",
+			Model:  resp.Model,
+			Choices: []StreamChoiceHTTP{
+				{
+					Index:        choice.Index,
+					Delta:        ChatMessageDeltaHTTP{Role: "assistant", Content: content},
+					FinishReason: choice.FinishReason,
+				},
+			},
+			Usage: &resp.Usage,
+		}); err != nil {
+			return fmt.Errorf("handle chunk failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// Embedding Anthropic 目前不提供 Embedding API
+func (p *AnthropicProvider) Embedding(ctx context.Context, input []string) (*EmbeddingHTTPResponse, error) {
+	return nil, fmt.Errorf("anthropic provider does not support embedding")
+}
@@ -0,0 +1,108 @@
+package llmproxy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"bot_agent/gateway/internal/cache"
+)
+
+// cacheReplayWordInterval 控制缓存命中回放流式 chunk 的节奏，避免客户端瞬间收到整段响应
+// 导致依赖"逐字出现"体验的 SSE 前端出现异常（如打字机效果突然跳变）
+const cacheReplayWordInterval = 20 * time.Millisecond
+
+// SetCache 设置语义缓存；设置后 ChatCompletion/ChatCompletionStream 会按 req.CachePolicy 读写缓存
+func (s *LLMProxyService) SetCache(c *cache.Cache) {
+	s.semanticCache = c
+}
+
+// Embed 对外暴露的 Embedding 调用入口，供上层（如 main.go）构造 cache.EmbedFunc 时复用现有路由/直连逻辑
+// 不做配额预检和日志：缓存自身的 embedding 调用属于内部开销，不计入业务配额
+func (s *LLMProxyService) Embed(ctx context.Context, deploymentID string, texts []string) ([]float32, error) {
+	var resp *EmbeddingHTTPResponse
+	var err error
+	if s.router != nil {
+		resp, err = s.router.Embedding(ctx, deploymentID, texts)
+	} else if s.client != nil {
+		resp, err = s.client.GetEmbedding(ctx, deploymentID, "", texts)
+	} else {
+		return nil, fmt.Errorf("LLM client not initialized")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("embedding response is empty")
+	}
+	return resp.Data[0].Embedding, nil
+}
+
+// promptTextForCache 拼接请求中的 user 消息作为缓存检索的语义文本
+// 只取纯文本内容：多模态消息（图片等）暂不参与缓存命中判断
+func promptTextForCache(httpReq *ChatCompletionHTTPRequest) string {
+	var sb strings.Builder
+	for _, msg := range httpReq.Messages {
+		if msg.Role != "user" {
+			continue
+		}
+		if text, ok := msg.Content.(string); ok {
+			sb.WriteString(text)
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// replayCachedStream 把缓存命中的完整响应拆成若干个增量 chunk 回放，模拟真实流式输出的节奏
+// 使依赖逐字增量渲染的下游 SSE 客户端不会因为缓存命中而行为异常
+func replayCachedStream(httpResp *ChatCompletionHTTPResponse, sendChunk func(*ChatCompletionChunkHTTP) error) error {
+	if len(httpResp.Choices) == 0 {
+		return nil
+	}
+	choice := httpResp.Choices[0]
+	content, _ := choice.Message.Content.(string)
+	words := strings.Fields(content)
+	if len(words) == 0 {
+		words = []string{""}
+	}
+
+	for i, word := range words {
+		text := word
+		if i < len(words)-1 {
+			text += " "
+		}
+		chunk := &ChatCompletionChunkHTTP{
+			ID:      httpResp.ID,
+			Object:  "chat.completion.chunk",
+			Created: httpResp.Created,
+			Model:   httpResp.Model,
+			Choices: []StreamChoiceHTTP{
+				{
+					Index: choice.Index,
+					Delta: ChatMessageDeltaHTTP{Content: text},
+				},
+			},
+		}
+		if i == 0 {
+			chunk.Choices[0].Delta.Role = choice.Message.Role
+		}
+		if err := sendChunk(chunk); err != nil {
+			return err
+		}
+		time.Sleep(cacheReplayWordInterval)
+	}
+
+	finishChunk := &ChatCompletionChunkHTTP{
+		ID:      httpResp.ID,
+		Object:  "chat.completion.chunk",
+		Created: httpResp.Created,
+		Model:   httpResp.Model,
+		Choices: []StreamChoiceHTTP{
+			{Index: choice.Index, Delta: ChatMessageDeltaHTTP{}, FinishReason: choice.FinishReason},
+		},
+		Usage: &httpResp.Usage,
+	}
+	return sendChunk(finishChunk)
+}
@@ -0,0 +1,546 @@
+package llmproxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+
+	"bot_agent/gateway/internal/cache"
+	"bot_agent/gateway/internal/logger"
+)
+
+// cachingMaxTemperature 是参与语义缓存的请求允许的最高 temperature，超过这个值的请求通常
+// 是需要多样化输出的创造性场景，复用历史响应会明显损害体验，因此直接跳过缓存
+const cachingMaxTemperature = 0.3
+
+// cachingDefaultThreshold 是未显式配置时使用的余弦相似度命中阈值，和 cache.Cache（Milvus 版语义缓存）保持一致
+const cachingDefaultThreshold = 0.95
+
+// CacheEntry 是 CacheStore 里的一条语义缓存记录：归一化 prompt 的 embedding 向量和对应的完整响应 JSON
+type CacheEntry struct {
+	DeploymentID string
+	Embedding    []float32
+	ResponseJSON string
+}
+
+// CacheStore 是 CachingClient 依赖的存储后端抽象：Get/Set/IsExist/Delete 按 key 精确存取，
+// 和 wechat 缓存外部文档里的 Cache 接口是同一种形状；Nearest 是语义缓存真正依赖的操作，
+// 在 deploymentID 命名空间下检索与给定向量余弦相似度最高的一条记录
+type CacheStore interface {
+	Get(ctx context.Context, key string) (*CacheEntry, bool, error)
+	Set(ctx context.Context, key string, entry *CacheEntry, ttl time.Duration) error
+	IsExist(ctx context.Context, key string) (bool, error)
+	Delete(ctx context.Context, key string) error
+	// Nearest 返回 minScore 以上相似度最高的记录；没有满足条件的记录时 entry 为 nil 且 err 为 nil
+	Nearest(ctx context.Context, deploymentID string, vector []float32, minScore float32) (entry *CacheEntry, score float32, err error)
+}
+
+// cosineSimilarity 计算两个向量的余弦相似度，维度不一致时视为完全不相似
+func cosineSimilarity(a, b []float32) float32 {
+	if len(a) == 0 || len(a) != len(b) {
+		return -1
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
+
+// normalizedMessagesHash 把拼接后的 prompt 文本做归一化（小写 + 合并空白）后取 sha256，
+// 用作精确 key 的一部分；embedding 向量才是命中判定的依据，这个 hash 只是给存储分桶用
+func normalizedMessagesHash(promptText string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(promptText)), " ")
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// cachingStoreKey 按 (deploymentID, normalized-messages-hash) 拼出 CacheStore 里的 key；
+// RedisCacheStore.Nearest 依赖这个 "deploymentID:" 前缀做 SCAN，改动这里要同步改 Nearest 的 pattern
+func cachingStoreKey(deploymentID, hash string) string {
+	return deploymentID + ":" + hash
+}
+
+// MemoryCacheStore 是 CacheStore 的进程内实现：适合单实例部署或没有 Redis 的小规模场景，
+// Nearest 对 deploymentID 命名空间下的所有记录做线性扫描，记录量大时建议换用 RedisCacheStore
+// 或直接复用 cache.Cache（基于 Milvus 的向量索引，见 internal/cache）
+type MemoryCacheStore struct {
+	mu      sync.RWMutex
+	entries map[string]memoryCacheRecord
+}
+
+type memoryCacheRecord struct {
+	entry     *CacheEntry
+	expiresAt time.Time // 零值表示永不过期
+}
+
+// NewMemoryCacheStore 创建一个空的内存缓存后端
+func NewMemoryCacheStore() *MemoryCacheStore {
+	return &MemoryCacheStore{entries: make(map[string]memoryCacheRecord)}
+}
+
+func (s *MemoryCacheStore) Get(ctx context.Context, key string) (*CacheEntry, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rec, ok := s.entries[key]
+	if !ok || rec.expired() {
+		return nil, false, nil
+	}
+	return rec.entry, true, nil
+}
+
+func (r memoryCacheRecord) expired() bool {
+	return !r.expiresAt.IsZero() && time.Now().After(r.expiresAt)
+}
+
+func (s *MemoryCacheStore) Set(ctx context.Context, key string, entry *CacheEntry, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+	s.entries[key] = memoryCacheRecord{entry: entry, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *MemoryCacheStore) IsExist(ctx context.Context, key string) (bool, error) {
+	_, ok, err := s.Get(ctx, key)
+	return ok, err
+}
+
+func (s *MemoryCacheStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryCacheStore) Nearest(ctx context.Context, deploymentID string, vector []float32, minScore float32) (*CacheEntry, float32, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prefix := deploymentID + ":"
+	var best *CacheEntry
+	var bestScore float32 = -1
+	for key, rec := range s.entries {
+		if rec.expired() || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		score := cosineSimilarity(vector, rec.entry.Embedding)
+		if score > bestScore {
+			bestScore, best = score, rec.entry
+		}
+	}
+
+	if best == nil || bestScore < minScore {
+		return nil, 0, nil
+	}
+	return best, bestScore, nil
+}
+
+// RedisCacheStore 是 CacheStore 的 Redis 实现：适合多副本网关共享同一份语义缓存。
+// Redis 本身不提供向量检索，Nearest 用 SCAN 取回 deploymentID 命名空间下的所有记录后在客户端
+// 算余弦相似度，和 MemoryCacheStore 是同一种线性扫描的权衡，只是换成了跨进程共享存储；
+// 记录量级较大时同样建议换用 cache.Cache（Milvus 版）
+type RedisCacheStore struct {
+	client *redis.Client
+	prefix string // 所有 key 的公共前缀，避免和同一个 Redis 实例上的其它业务数据冲突
+}
+
+// NewRedisCacheStore 创建 Redis 缓存后端，prefix 为空时使用默认前缀
+func NewRedisCacheStore(client *redis.Client, prefix string) *RedisCacheStore {
+	if prefix == "" {
+		prefix = "bot_agent:llmcache:"
+	}
+	return &RedisCacheStore{client: client, prefix: prefix}
+}
+
+func (s *RedisCacheStore) redisKey(key string) string { return s.prefix + key }
+
+func (s *RedisCacheStore) Get(ctx context.Context, key string) (*CacheEntry, bool, error) {
+	data, err := s.client.Get(ctx, s.redisKey(key)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("redis get failed: %w", err)
+	}
+	var entry CacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false, fmt.Errorf("unmarshal cache entry failed: %w", err)
+	}
+	return &entry, true, nil
+}
+
+func (s *RedisCacheStore) Set(ctx context.Context, key string, entry *CacheEntry, ttl time.Duration) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal cache entry failed: %w", err)
+	}
+	if err := s.client.Set(ctx, s.redisKey(key), data, ttl).Err(); err != nil {
+		return fmt.Errorf("redis set failed: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisCacheStore) IsExist(ctx context.Context, key string) (bool, error) {
+	n, err := s.client.Exists(ctx, s.redisKey(key)).Result()
+	if err != nil {
+		return false, fmt.Errorf("redis exists failed: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (s *RedisCacheStore) Delete(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, s.redisKey(key)).Err(); err != nil {
+		return fmt.Errorf("redis del failed: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisCacheStore) Nearest(ctx context.Context, deploymentID string, vector []float32, minScore float32) (*CacheEntry, float32, error) {
+	pattern := s.redisKey(deploymentID + ":*")
+	iter := s.client.Scan(ctx, 0, pattern, 0).Iterator()
+
+	var best *CacheEntry
+	var bestScore float32 = -1
+	for iter.Next(ctx) {
+		data, err := s.client.Get(ctx, iter.Val()).Bytes()
+		if err != nil {
+			continue // 扫描途中过期/被并发删除都只是少算一条候选，不算失败
+		}
+		var entry CacheEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			continue
+		}
+		if score := cosineSimilarity(vector, entry.Embedding); score > bestScore {
+			bestScore, best = score, &entry
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, 0, fmt.Errorf("redis scan failed: %w", err)
+	}
+
+	if best == nil || bestScore < minScore {
+		return nil, 0, nil
+	}
+	return best, bestScore, nil
+}
+
+// CacheMetrics 按 deployment 维度统计语义缓存的命中率、省下的 token 数和估算节省的美元成本，
+// 实现 prometheus.Collector，风格和 Metrics（metrics.go）保持一致，可以注册到同一个 Registry
+type CacheMetrics struct {
+	mu    sync.RWMutex
+	stats map[string]*cacheDeploymentStat
+
+	hitsDesc             *prometheus.Desc
+	missesDesc           *prometheus.Desc
+	bypassDesc           *prometheus.Desc
+	promptTokensDesc     *prometheus.Desc
+	completionTokensDesc *prometheus.Desc
+	usdSavedDesc         *prometheus.Desc
+}
+
+type cacheDeploymentStat struct {
+	deployment string
+
+	mu                    sync.Mutex
+	hits                  int64
+	misses                int64
+	bypassed              int64
+	promptTokensSaved     int64
+	completionTokensSaved int64
+	usdSaved              float64
+}
+
+// NewCacheMetrics 创建一个空的语义缓存指标收集器
+func NewCacheMetrics() *CacheMetrics {
+	labels := []string{"deployment"}
+	return &CacheMetrics{
+		stats: make(map[string]*cacheDeploymentStat),
+		hitsDesc: prometheus.NewDesc(
+			"bot_agent_llmcache_hits_total",
+			"语义缓存命中次数，按部署分类", labels, nil),
+		missesDesc: prometheus.NewDesc(
+			"bot_agent_llmcache_misses_total",
+			"语义缓存未命中（已尝试查找但低于阈值）次数，按部署分类", labels, nil),
+		bypassDesc: prometheus.NewDesc(
+			"bot_agent_llmcache_bypassed_total",
+			"因 Tools/Stream/Temperature 超过阈值而跳过缓存的请求数，按部署分类", labels, nil),
+		promptTokensDesc: prometheus.NewDesc(
+			"bot_agent_llmcache_prompt_tokens_saved_total",
+			"缓存命中省下的 prompt token 累计数，按部署分类", labels, nil),
+		completionTokensDesc: prometheus.NewDesc(
+			"bot_agent_llmcache_completion_tokens_saved_total",
+			"缓存命中省下的 completion token 累计数，按部署分类", labels, nil),
+		usdSavedDesc: prometheus.NewDesc(
+			"bot_agent_llmcache_cost_usd_saved_total",
+			"按 pricing 表估算的累计节省成本（美元），按部署分类", labels, nil),
+	}
+}
+
+func (m *CacheMetrics) statFor(deployment string) *cacheDeploymentStat {
+	m.mu.RLock()
+	s, ok := m.stats[deployment]
+	m.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.stats[deployment]; ok {
+		return s
+	}
+	s = &cacheDeploymentStat{deployment: deployment}
+	m.stats[deployment] = s
+	return s
+}
+
+func (m *CacheMetrics) recordHit(deployment string, promptTokens, completionTokens int32, usdSaved float64) {
+	s := m.statFor(deployment)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hits++
+	s.promptTokensSaved += int64(promptTokens)
+	s.completionTokensSaved += int64(completionTokens)
+	s.usdSaved += usdSaved
+}
+
+func (m *CacheMetrics) recordMiss(deployment string) {
+	s := m.statFor(deployment)
+	s.mu.Lock()
+	s.misses++
+	s.mu.Unlock()
+}
+
+func (m *CacheMetrics) recordBypass(deployment string) {
+	s := m.statFor(deployment)
+	s.mu.Lock()
+	s.bypassed++
+	s.mu.Unlock()
+}
+
+// HitRate 返回 deployment 维度的命中率（hits / (hits+misses)），还没有任何查找时返回 0
+func (m *CacheMetrics) HitRate(deployment string) float64 {
+	s := m.statFor(deployment)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total := s.hits + s.misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.hits) / float64(total)
+}
+
+// Describe 实现 prometheus.Collector
+func (m *CacheMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.hitsDesc
+	ch <- m.missesDesc
+	ch <- m.bypassDesc
+	ch <- m.promptTokensDesc
+	ch <- m.completionTokensDesc
+	ch <- m.usdSavedDesc
+}
+
+// Collect 实现 prometheus.Collector
+func (m *CacheMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.mu.RLock()
+	stats := make([]*cacheDeploymentStat, 0, len(m.stats))
+	for _, s := range m.stats {
+		stats = append(stats, s)
+	}
+	m.mu.RUnlock()
+
+	for _, s := range stats {
+		s.mu.Lock()
+		labels := []string{s.deployment}
+		ch <- prometheus.MustNewConstMetric(m.hitsDesc, prometheus.CounterValue, float64(s.hits), labels...)
+		ch <- prometheus.MustNewConstMetric(m.missesDesc, prometheus.CounterValue, float64(s.misses), labels...)
+		ch <- prometheus.MustNewConstMetric(m.bypassDesc, prometheus.CounterValue, float64(s.bypassed), labels...)
+		ch <- prometheus.MustNewConstMetric(m.promptTokensDesc, prometheus.CounterValue, float64(s.promptTokensSaved), labels...)
+		ch <- prometheus.MustNewConstMetric(m.completionTokensDesc, prometheus.CounterValue, float64(s.completionTokensSaved), labels...)
+		ch <- prometheus.MustNewConstMetric(m.usdSavedDesc, prometheus.CounterValue, s.usdSaved, labels...)
+		s.mu.Unlock()
+	}
+}
+
+// CachingClient 把一个 Provider 包一层语义缓存：对请求里的 user 消息做 embedding，在 CacheStore
+// 里找相似度最高的历史响应，超过阈值直接返回命中的响应，否则转发给下游 Provider 并异步写回缓存。
+// 只有非流式、不带 Tools、且 temperature 不超过 cachingMaxTemperature 的请求才参与缓存判定——
+// 这类请求的输出通常是确定性或重复性很高的，例如游戏里反复出现的规则问答；流式、工具调用或
+// 高 temperature 的创造性请求直接透传给下游，不读也不写缓存
+type CachingClient struct {
+	inner        Provider
+	deploymentID string
+	store        CacheStore
+	embed        cache.EmbedFunc
+	threshold    float32
+	ttl          time.Duration
+	pricing      ModelPricing
+	metrics      *CacheMetrics
+}
+
+// NewCachingClient 创建语义缓存装饰器，threshold<=0 时使用 cachingDefaultThreshold，ttl<=0 时使用 1 小时；
+// metrics 可以为 nil，表示不上报命中率/节省成本指标
+func NewCachingClient(inner Provider, deploymentID string, store CacheStore, embed cache.EmbedFunc, threshold float32, ttl time.Duration, pricing ModelPricing, metrics *CacheMetrics) *CachingClient {
+	if threshold <= 0 {
+		threshold = cachingDefaultThreshold
+	}
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &CachingClient{
+		inner:        inner,
+		deploymentID: deploymentID,
+		store:        store,
+		embed:        embed,
+		threshold:    threshold,
+		ttl:          ttl,
+		pricing:      pricing,
+		metrics:      metrics,
+	}
+}
+
+func (c *CachingClient) Name() string { return c.inner.Name() }
+
+func (c *CachingClient) Supports(model string) bool { return c.inner.Supports(model) }
+
+// cacheable 判断这次请求是否应该参与语义缓存：带 Tools、开启 Stream 或 temperature 偏高的
+// 请求都跳过，理由见 CachingClient 的类型注释
+func cacheable(req *ChatCompletionHTTPRequest) bool {
+	if len(req.Tools) > 0 || req.Stream {
+		return false
+	}
+	if req.Temperature != nil && *req.Temperature > cachingMaxTemperature {
+		return false
+	}
+	return true
+}
+
+func (c *CachingClient) ChatCompletion(ctx context.Context, req *ChatCompletionHTTPRequest) (*ChatCompletionHTTPResponse, error) {
+	if !cacheable(req) || c.store == nil || c.embed == nil {
+		if c.metrics != nil {
+			c.metrics.recordBypass(c.deploymentID)
+		}
+		return c.inner.ChatCompletion(ctx, req)
+	}
+
+	promptText := promptTextForCache(req)
+	vector, err := c.embed(ctx, []string{promptText})
+	if err != nil {
+		logger.Warn("caching client embedding failed, bypassing cache: %v", err)
+		return c.inner.ChatCompletion(ctx, req)
+	}
+
+	if entry, score, err := c.store.Nearest(ctx, c.deploymentID, vector, c.threshold); err != nil {
+		logger.Warn("caching client lookup failed: %v", err)
+	} else if entry != nil {
+		var resp ChatCompletionHTTPResponse
+		if err := json.Unmarshal([]byte(entry.ResponseJSON), &resp); err != nil {
+			logger.Warn("caching client unmarshal cached response failed: %v", err)
+		} else {
+			logger.Debugw("semantic cache hit", "deployment_id", c.deploymentID, "score", score)
+			if c.metrics != nil {
+				usdSaved := c.pricing.cost(resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+				c.metrics.recordHit(c.deploymentID, resp.Usage.PromptTokens, resp.Usage.CompletionTokens, usdSaved)
+			}
+			return &resp, nil
+		}
+	}
+
+	resp, err := c.inner.ChatCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if c.metrics != nil {
+		c.metrics.recordMiss(c.deploymentID)
+	}
+
+	c.storeAsync(promptText, vector, resp)
+	return resp, nil
+}
+
+// storeAsync 异步把一次上游响应写入 CacheStore，不阻塞调用方返回结果给客户端
+func (c *CachingClient) storeAsync(promptText string, vector []float32, resp *ChatCompletionHTTPResponse) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		logger.Warn("caching client marshal response failed: %v", err)
+		return
+	}
+	key := cachingStoreKey(c.deploymentID, normalizedMessagesHash(promptText))
+	entry := &CacheEntry{DeploymentID: c.deploymentID, Embedding: vector, ResponseJSON: string(data)}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := c.store.Set(ctx, key, entry, c.ttl); err != nil {
+			logger.Warn("caching client store failed: %v", err)
+		}
+	}()
+}
+
+// ChatCompletionStream 语义缓存只覆盖非流式请求（见 cacheable），流式请求直接透传给下游 Provider
+func (c *CachingClient) ChatCompletionStream(ctx context.Context, req *ChatCompletionHTTPRequest, handler StreamChunkHandler) error {
+	if c.metrics != nil {
+		c.metrics.recordBypass(c.deploymentID)
+	}
+	return c.inner.ChatCompletionStream(ctx, req, handler)
+}
+
+// Embedding 透传给下游 Provider；CachingClient 自己调用 embed 只是为了算相似度，不缓存 Embedding 请求本身
+func (c *CachingClient) Embedding(ctx context.Context, input []string) (*EmbeddingHTTPResponse, error) {
+	return c.inner.Embedding(ctx, input)
+}
+
+// Close 关闭底层 Provider 持有的连接（如果它支持 Close）
+func (c *CachingClient) Close() error {
+	if closer, ok := c.inner.(interface{ Close() error }); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// wrapWithCache 按 DeploymentConfig.CacheStore 给 NewRouter 里刚建好的 provider 包一层 CachingClient；
+// embedding 向量通过 Router 自身路由到 CacheEmbedDeployment（默认复用本部署），这样缓存可以单独指定
+// 一个更便宜的 embedding 模型，而不必和这个部署的 chat 模型绑死
+func (r *Router) wrapWithCache(name string, provider Provider, d DeploymentConfig) Provider {
+	embedDeployment := d.CacheEmbedDeployment
+	if embedDeployment == "" {
+		embedDeployment = d.LogicalName
+	}
+
+	embedFunc := func(ctx context.Context, texts []string) ([]float32, error) {
+		resp, err := r.Embedding(ctx, embedDeployment, texts)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Data) == 0 {
+			return nil, fmt.Errorf("embedding response is empty")
+		}
+		return resp.Data[0].Embedding, nil
+	}
+
+	// 复用 Metrics 的价目表，避免缓存命中节省额和 Metrics 上报的真实成本出自两份不同的单价配置
+	var pricing ModelPricing
+	if d.Metrics != nil {
+		pricing = d.Metrics.pricingFor(d.Model)
+	}
+
+	return NewCachingClient(provider, name, d.CacheStore, embedFunc, d.CacheThreshold, d.CacheTTL, pricing, d.CacheMetrics)
+}
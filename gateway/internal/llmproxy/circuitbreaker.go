@@ -0,0 +1,95 @@
+package llmproxy
+
+import (
+	"sync"
+	"time"
+)
+
+// breakerState 熔断器状态
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker 简单的三态熔断器：关闭 -> (连续失败达阈值) -> 打开 -> (冷却结束) -> 半开 -> 探测成功则关闭/失败则重新打开
+type circuitBreaker struct {
+	mu              sync.Mutex
+	state           breakerState
+	failureCount    int
+	failureThresh   int
+	cooldown        time.Duration
+	openedAt        time.Time
+	halfOpenAllowed bool // 半开状态下是否已经放行过一个探测请求
+}
+
+// newCircuitBreaker 创建熔断器，failureThresh 为触发熔断的连续失败次数，cooldown 为熔断后多久进入半开态
+func newCircuitBreaker(failureThresh int, cooldown time.Duration) *circuitBreaker {
+	if failureThresh <= 0 {
+		failureThresh = 5
+	}
+	if cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+	return &circuitBreaker{failureThresh: failureThresh, cooldown: cooldown}
+}
+
+// allow 判断当前是否允许放行一次请求
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		// 冷却结束，进入半开态，放行一个探测请求
+		b.state = breakerHalfOpen
+		b.halfOpenAllowed = true
+		return true
+	case breakerHalfOpen:
+		if b.halfOpenAllowed {
+			return false
+		}
+		b.halfOpenAllowed = true
+		return true
+	default:
+		return true
+	}
+}
+
+// recordSuccess 记录一次成功调用，关闭熔断器并清空失败计数
+func (b *circuitBreaker) recordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = breakerClosed
+	b.failureCount = 0
+}
+
+// recordFailure 记录一次失败调用，达到阈值或半开探测失败时打开熔断器
+func (b *circuitBreaker) recordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+		return
+	}
+
+	b.failureCount++
+	if b.failureCount >= b.failureThresh {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// isOpen 仅用于指标/调试展示当前状态
+func (b *circuitBreaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == breakerOpen
+}
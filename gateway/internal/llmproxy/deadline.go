@@ -0,0 +1,55 @@
+package llmproxy
+
+import (
+	"sync"
+	"time"
+)
+
+// deadlineTimer 是一个可重复设置的截止时间：和 netstack 里端点的 setDeadline 做法一样，
+// 用一个 *time.Timer 加一个到期即关闭的 cancelCh 表示"是否已超时"，每次重新 set 都会换一个
+// 新的 cancelCh，这样旧的到期事件不会污染下一次等待。ChatCompletionStream 的读循环把这个
+// cancelCh 和 ctx.Done() 一起 select，谁先到就按谁处理
+type deadlineTimer struct {
+	mu       sync.Mutex
+	timer    *time.Timer
+	cancelCh chan struct{}
+}
+
+func newDeadlineTimer() *deadlineTimer {
+	return &deadlineTimer{cancelCh: make(chan struct{})}
+}
+
+// set 重新设置超时时长，d<=0 表示不设超时（cancelCh 只会在下次 set 时才会变化，永不自己关闭）
+func (d *deadlineTimer) set(timeout time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.cancelCh = make(chan struct{})
+	if timeout <= 0 {
+		return
+	}
+
+	ch := d.cancelCh
+	d.timer = time.AfterFunc(timeout, func() { close(ch) })
+}
+
+// cancel 返回当前这一轮的到期通道，只有超时到期时才会关闭变为可读；stop 不会关闭它
+func (d *deadlineTimer) cancel() <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cancelCh
+}
+
+// stop 停止计时器但不关闭 cancelCh，调用方正常读完流之后用它清理，避免定时器 leak
+func (d *deadlineTimer) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+}
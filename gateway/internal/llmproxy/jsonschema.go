@@ -0,0 +1,136 @@
+package llmproxy
+
+import (
+	"fmt"
+	"sort"
+)
+
+// JSONSchema 是调用方传入的 JSON Schema（OAS3 子集：type/properties/required/items/enum/
+// minimum/maximum/minLength/maxLength），用 map 而不是强类型结构体表示，因为调用方的 schema
+// 形状各不相同，没有必要为此定义一整套 Go 类型
+type JSONSchema map[string]interface{}
+
+// validateJSONSchema 校验 data 是否符合 schema，返回全部校验错误（而不是遇到第一个就停），
+// 这样自我修复重试时可以把所有问题一次性喂给模型，减少往返次数。path 用于错误信息定位字段
+func validateJSONSchema(schema JSONSchema, data interface{}, path string) []string {
+	if schema == nil {
+		return nil
+	}
+
+	var errs []string
+
+	if enumVals, ok := schema["enum"].([]interface{}); ok {
+		if !containsValue(enumVals, data) {
+			errs = append(errs, fmt.Sprintf("%s: value %v is not one of enum %v", fieldLabel(path), data, enumVals))
+			return errs // 命中 enum 校验失败没必要继续往下判类型
+		}
+	}
+
+	schemaType, _ := schema["type"].(string)
+	switch schemaType {
+	case "object":
+		obj, ok := data.(map[string]interface{})
+		if !ok {
+			return append(errs, fmt.Sprintf("%s: expected object, got %T", fieldLabel(path), data))
+		}
+
+		required, _ := schema["required"].([]interface{})
+		for _, r := range required {
+			key, _ := r.(string)
+			if _, present := obj[key]; !present {
+				errs = append(errs, fmt.Sprintf("%s: missing required field %q", fieldLabel(path), key))
+			}
+		}
+
+		if props, ok := schema["properties"].(map[string]interface{}); ok {
+			// 按字段名排序，保证同一个失败集合每次生成的错误文案顺序一致，重试提示稳定可读
+			keys := make([]string, 0, len(props))
+			for k := range props {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				val, present := obj[key]
+				if !present {
+					continue // 缺失已经由 required 检查覆盖，可选字段缺失不是错误
+				}
+				propSchema, _ := props[key].(map[string]interface{})
+				errs = append(errs, validateJSONSchema(JSONSchema(propSchema), val, joinPath(path, key))...)
+			}
+		}
+
+	case "array":
+		arr, ok := data.([]interface{})
+		if !ok {
+			return append(errs, fmt.Sprintf("%s: expected array, got %T", fieldLabel(path), data))
+		}
+		if itemSchema, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range arr {
+				errs = append(errs, validateJSONSchema(JSONSchema(itemSchema), item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+
+	case "string":
+		s, ok := data.(string)
+		if !ok {
+			return append(errs, fmt.Sprintf("%s: expected string, got %T", fieldLabel(path), data))
+		}
+		if minLen, ok := numberField(schema, "minLength"); ok && float64(len(s)) < minLen {
+			errs = append(errs, fmt.Sprintf("%s: length %d is shorter than minLength %v", fieldLabel(path), len(s), minLen))
+		}
+		if maxLen, ok := numberField(schema, "maxLength"); ok && float64(len(s)) > maxLen {
+			errs = append(errs, fmt.Sprintf("%s: length %d is longer than maxLength %v", fieldLabel(path), len(s), maxLen))
+		}
+
+	case "number", "integer":
+		n, ok := data.(float64)
+		if !ok {
+			return append(errs, fmt.Sprintf("%s: expected %s, got %T", fieldLabel(path), schemaType, data))
+		}
+		if schemaType == "integer" && n != float64(int64(n)) {
+			errs = append(errs, fmt.Sprintf("%s: expected integer, got fractional number %v", fieldLabel(path), n))
+		}
+		if min, ok := numberField(schema, "minimum"); ok && n < min {
+			errs = append(errs, fmt.Sprintf("%s: value %v is less than minimum %v", fieldLabel(path), n, min))
+		}
+		if max, ok := numberField(schema, "maximum"); ok && n > max {
+			errs = append(errs, fmt.Sprintf("%s: value %v is greater than maximum %v", fieldLabel(path), n, max))
+		}
+
+	case "boolean":
+		if _, ok := data.(bool); !ok {
+			errs = append(errs, fmt.Sprintf("%s: expected boolean, got %T", fieldLabel(path), data))
+		}
+	}
+
+	return errs
+}
+
+func numberField(schema JSONSchema, key string) (float64, bool) {
+	v, ok := schema[key].(float64)
+	return v, ok
+}
+
+func containsValue(vals []interface{}, target interface{}) bool {
+	for _, v := range vals {
+		if fmt.Sprint(v) == fmt.Sprint(target) {
+			return true
+		}
+	}
+	return false
+}
+
+func fieldLabel(path string) string {
+	if path == "" {
+		return "$"
+	}
+	return path
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,169 @@
+package llmproxy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateJSONSchema_NilSchemaAlwaysPasses(t *testing.T) {
+	if errs := validateJSONSchema(nil, map[string]interface{}{"a": 1}, ""); errs != nil {
+		t.Fatalf("validateJSONSchema(nil, ...) = %v, want nil", errs)
+	}
+}
+
+func TestValidateJSONSchema_ObjectRequiredAndProperties(t *testing.T) {
+	schema := JSONSchema{
+		"type":     "object",
+		"required": []interface{}{"name", "age"},
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "string", "minLength": 1.0},
+			"age":  map[string]interface{}{"type": "integer", "minimum": 0.0},
+		},
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		data := map[string]interface{}{"name": "alice", "age": 30.0}
+		if errs := validateJSONSchema(schema, data, ""); len(errs) != 0 {
+			t.Fatalf("expected no errors, got %v", errs)
+		}
+	})
+
+	t.Run("missing required field", func(t *testing.T) {
+		data := map[string]interface{}{"name": "alice"}
+		errs := validateJSONSchema(schema, data, "")
+		if len(errs) != 1 {
+			t.Fatalf("expected exactly one error, got %v", errs)
+		}
+	})
+
+	t.Run("wrong top-level type", func(t *testing.T) {
+		errs := validateJSONSchema(schema, "not an object", "")
+		if len(errs) != 1 {
+			t.Fatalf("expected exactly one error, got %v", errs)
+		}
+	})
+
+	t.Run("accumulates multiple property errors instead of stopping at the first", func(t *testing.T) {
+		data := map[string]interface{}{"name": "", "age": -1.0}
+		errs := validateJSONSchema(schema, data, "")
+		if len(errs) != 2 {
+			t.Fatalf("expected two errors (name minLength + age minimum), got %v", errs)
+		}
+	})
+}
+
+func TestValidateJSONSchema_Array(t *testing.T) {
+	schema := JSONSchema{
+		"type":  "array",
+		"items": map[string]interface{}{"type": "string"},
+	}
+
+	if errs := validateJSONSchema(schema, []interface{}{"a", "b"}, ""); len(errs) != 0 {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+
+	errs := validateJSONSchema(schema, []interface{}{"a", 1.0}, "")
+	if len(errs) != 1 {
+		t.Fatalf("expected one error for the non-string element, got %v", errs)
+	}
+
+	if errs := validateJSONSchema(schema, "not an array", ""); len(errs) != 1 {
+		t.Fatalf("expected one type-mismatch error, got %v", errs)
+	}
+}
+
+func TestValidateJSONSchema_StringLength(t *testing.T) {
+	schema := JSONSchema{"type": "string", "minLength": 2.0, "maxLength": 4.0}
+
+	cases := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"too short", "a", true},
+		{"too long", "abcde", true},
+		{"just right", "abc", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := validateJSONSchema(schema, tc.value, "")
+			if tc.wantErr && len(errs) == 0 {
+				t.Fatalf("expected an error for %q, got none", tc.value)
+			}
+			if !tc.wantErr && len(errs) != 0 {
+				t.Fatalf("expected no error for %q, got %v", tc.value, errs)
+			}
+		})
+	}
+}
+
+func TestValidateJSONSchema_IntegerRejectsFractional(t *testing.T) {
+	schema := JSONSchema{"type": "integer"}
+
+	if errs := validateJSONSchema(schema, 3.0, ""); len(errs) != 0 {
+		t.Fatalf("expected no error for a whole number, got %v", errs)
+	}
+	if errs := validateJSONSchema(schema, 3.5, ""); len(errs) != 1 {
+		t.Fatalf("expected one error for a fractional value, got %v", errs)
+	}
+}
+
+func TestValidateJSONSchema_NumberBounds(t *testing.T) {
+	schema := JSONSchema{"type": "number", "minimum": 0.0, "maximum": 10.0}
+
+	if errs := validateJSONSchema(schema, -1.0, ""); len(errs) != 1 {
+		t.Fatalf("expected a below-minimum error, got %v", errs)
+	}
+	if errs := validateJSONSchema(schema, 11.0, ""); len(errs) != 1 {
+		t.Fatalf("expected an above-maximum error, got %v", errs)
+	}
+	if errs := validateJSONSchema(schema, 5.0, ""); len(errs) != 0 {
+		t.Fatalf("expected no error within bounds, got %v", errs)
+	}
+}
+
+func TestValidateJSONSchema_Boolean(t *testing.T) {
+	schema := JSONSchema{"type": "boolean"}
+	if errs := validateJSONSchema(schema, true, ""); len(errs) != 0 {
+		t.Fatalf("expected no error, got %v", errs)
+	}
+	if errs := validateJSONSchema(schema, "true", ""); len(errs) != 1 {
+		t.Fatalf("expected a type-mismatch error, got %v", errs)
+	}
+}
+
+func TestValidateJSONSchema_Enum(t *testing.T) {
+	schema := JSONSchema{"enum": []interface{}{"red", "green", "blue"}}
+
+	if errs := validateJSONSchema(schema, "green", ""); len(errs) != 0 {
+		t.Fatalf("expected no error, got %v", errs)
+	}
+	if errs := validateJSONSchema(schema, "purple", ""); len(errs) != 1 {
+		t.Fatalf("expected one enum-mismatch error, got %v", errs)
+	}
+}
+
+func TestValidateJSONSchema_NestedObjectPathInErrorMessage(t *testing.T) {
+	schema := JSONSchema{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"user": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"email": map[string]interface{}{"type": "string", "minLength": 5.0},
+				},
+			},
+		},
+	}
+	data := map[string]interface{}{
+		"user": map[string]interface{}{"email": "a"},
+	}
+
+	errs := validateJSONSchema(schema, data, "")
+	if len(errs) != 1 {
+		t.Fatalf("expected exactly one error, got %v", errs)
+	}
+	if got, want := errs[0], "user.email"; !strings.Contains(got, want) {
+		t.Fatalf("error %q does not reference nested path %q", got, want)
+	}
+}
@@ -0,0 +1,331 @@
+package llmproxy
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"bot_agent/gateway/internal/config"
+)
+
+// ModelPricing 是某个模型每 1K token 的美元单价，用来把 Usage 换算成一个粗略的成本估计，
+// 不追求和账单分毫不差，只用来做预算告警和成本排查
+type ModelPricing struct {
+	InputUSDPer1K  float64
+	OutputUSDPer1K float64
+}
+
+// cost 按 prompt/completion token 数和单价估算一次调用的美元成本
+func (p ModelPricing) cost(promptTokens, completionTokens int32) float64 {
+	return float64(promptTokens)/1000*p.InputUSDPer1K + float64(completionTokens)/1000*p.OutputUSDPer1K
+}
+
+var metricsLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30, 60}
+
+// deploymentStat 是单个 deployment+model+op 维度下累计的用量、延迟和成本，op 取 "chat" 或 "embedding"
+type deploymentStat struct {
+	deployment string
+	model      string
+	op         string
+
+	mu               sync.Mutex
+	requests         int64
+	errors           int64
+	promptTokens     int64
+	completionTokens int64
+	costUSD          float64
+	latencyCounts    []uint64 // 累积分布，latencyCounts[i] 是耗时 <= metricsLatencyBuckets[i] 的次数
+	latencySum       float64
+	latencyCount     uint64
+}
+
+func newDeploymentStat(deployment, model, op string) *deploymentStat {
+	return &deploymentStat{
+		deployment:    deployment,
+		model:         model,
+		op:            op,
+		latencyCounts: make([]uint64, len(metricsLatencyBuckets)),
+	}
+}
+
+func (s *deploymentStat) observe(promptTokens, completionTokens int32, costUSD float64, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	if err != nil {
+		s.errors++
+	}
+	s.promptTokens += int64(promptTokens)
+	s.completionTokens += int64(completionTokens)
+	s.costUSD += costUSD
+
+	seconds := latency.Seconds()
+	s.latencySum += seconds
+	s.latencyCount++
+	for i, bound := range metricsLatencyBuckets {
+		if seconds <= bound {
+			s.latencyCounts[i]++
+		}
+	}
+}
+
+// Metrics 按 deployment+model+op 维度统计 LLMClient 每次调用的请求数、错误数、token 用量、
+// 估算成本和延迟分布，实现 prometheus.Collector，可以直接 Register 到任意 Registry，
+// 也可以用 HTTPHandler 单独挂一个只暴露这些指标的 /metrics 端点
+type Metrics struct {
+	mu      sync.RWMutex
+	stats   map[string]*deploymentStat
+	pricing map[string]ModelPricing
+
+	requestsDesc *prometheus.Desc
+	errorsDesc   *prometheus.Desc
+	promptDesc   *prometheus.Desc
+	compDesc     *prometheus.Desc
+	costDesc     *prometheus.Desc
+	latencyDesc  *prometheus.Desc
+}
+
+// NewMetrics 创建一个 Metrics，pricing 把模型名映射到单价，未命中的模型成本按 0 估算
+func NewMetrics(pricing map[string]ModelPricing) *Metrics {
+	if pricing == nil {
+		pricing = map[string]ModelPricing{}
+	}
+	labels := []string{"deployment", "model", "op"}
+	return &Metrics{
+		stats:   make(map[string]*deploymentStat),
+		pricing: pricing,
+		requestsDesc: prometheus.NewDesc(
+			"bot_agent_llmproxy_requests_total",
+			"LLM 代理请求总数，按部署、模型和操作类型（chat/embedding）分类", labels, nil),
+		errorsDesc: prometheus.NewDesc(
+			"bot_agent_llmproxy_errors_total",
+			"LLM 代理请求失败次数，按部署、模型和操作类型分类", labels, nil),
+		promptDesc: prometheus.NewDesc(
+			"bot_agent_llmproxy_prompt_tokens_total",
+			"累计消耗的 prompt token 数，按部署、模型和操作类型分类", labels, nil),
+		compDesc: prometheus.NewDesc(
+			"bot_agent_llmproxy_completion_tokens_total",
+			"累计消耗的 completion token 数，按部署、模型和操作类型分类", labels, nil),
+		costDesc: prometheus.NewDesc(
+			"bot_agent_llmproxy_cost_usd_total",
+			"按 pricing 表估算的累计成本（美元），按部署、模型和操作类型分类", labels, nil),
+		latencyDesc: prometheus.NewDesc(
+			"bot_agent_llmproxy_request_duration_seconds",
+			"LLM 代理单次调用耗时分布，按部署、模型和操作类型分类", labels, nil),
+	}
+}
+
+func (m *Metrics) statFor(deployment, model, op string) *deploymentStat {
+	key := deployment + "|" + model + "|" + op
+
+	m.mu.RLock()
+	s, ok := m.stats[key]
+	m.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.stats[key]; ok {
+		return s
+	}
+	s = newDeploymentStat(deployment, model, op)
+	m.stats[key] = s
+	return s
+}
+
+// RecordChatCompletion 记录一次 ChatCompletion/ChatCompletionStream 调用，usage 为 nil 表示
+// 调用失败或流式请求未开启 include_usage，此时只统计请求数/错误数/延迟，不计 token 和成本
+func (m *Metrics) RecordChatCompletion(deployment, model string, usage *UsageHTTP, latency time.Duration, err error) {
+	m.record(deployment, model, "chat", usage, latency, err)
+}
+
+// RecordEmbedding 记录一次 GetEmbedding 调用
+func (m *Metrics) RecordEmbedding(deployment, model string, usage *UsageHTTP, latency time.Duration, err error) {
+	m.record(deployment, model, "embedding", usage, latency, err)
+}
+
+func (m *Metrics) record(deployment, model, op string, usage *UsageHTTP, latency time.Duration, err error) {
+	var promptTokens, completionTokens int32
+	var costUSD float64
+	if usage != nil {
+		promptTokens, completionTokens = usage.PromptTokens, usage.CompletionTokens
+		costUSD = m.pricingFor(model).cost(promptTokens, completionTokens)
+	}
+	m.statFor(deployment, model, op).observe(promptTokens, completionTokens, costUSD, latency, err)
+}
+
+func (m *Metrics) pricingFor(model string) ModelPricing {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.pricing[model]
+}
+
+// CostUSD 是目前这个 Metrics 下所有维度自创建以来累计的估算成本，不按天清零；
+// Budget 不读取它，当日花费由 Budget.Record 单独累计
+func (m *Metrics) CostUSD() float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var total float64
+	for _, s := range m.stats {
+		s.mu.Lock()
+		total += s.costUSD
+		s.mu.Unlock()
+	}
+	return total
+}
+
+// Describe 实现 prometheus.Collector
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.requestsDesc
+	ch <- m.errorsDesc
+	ch <- m.promptDesc
+	ch <- m.compDesc
+	ch <- m.costDesc
+	ch <- m.latencyDesc
+}
+
+// Collect 实现 prometheus.Collector，按 deployment+model+op 维度逐个吐出当前累计值
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.mu.RLock()
+	stats := make([]*deploymentStat, 0, len(m.stats))
+	for _, s := range m.stats {
+		stats = append(stats, s)
+	}
+	m.mu.RUnlock()
+
+	for _, s := range stats {
+		s.mu.Lock()
+		labels := []string{s.deployment, s.model, s.op}
+		ch <- prometheus.MustNewConstMetric(m.requestsDesc, prometheus.CounterValue, float64(s.requests), labels...)
+		ch <- prometheus.MustNewConstMetric(m.errorsDesc, prometheus.CounterValue, float64(s.errors), labels...)
+		ch <- prometheus.MustNewConstMetric(m.promptDesc, prometheus.CounterValue, float64(s.promptTokens), labels...)
+		ch <- prometheus.MustNewConstMetric(m.compDesc, prometheus.CounterValue, float64(s.completionTokens), labels...)
+		ch <- prometheus.MustNewConstMetric(m.costDesc, prometheus.CounterValue, s.costUSD, labels...)
+
+		buckets := make(map[float64]uint64, len(metricsLatencyBuckets))
+		for i, bound := range metricsLatencyBuckets {
+			buckets[bound] = s.latencyCounts[i]
+		}
+		ch <- prometheus.MustNewConstHistogram(m.latencyDesc, s.latencyCount, s.latencySum, buckets, labels...)
+		s.mu.Unlock()
+	}
+}
+
+// HTTPHandler 返回一个只暴露这个 Metrics 的 /metrics 端点，不污染全局默认 Registry，
+// 调用方也可以不用这个方法，自己把 Metrics Register 到已有的 Registry 里
+func (m *Metrics) HTTPHandler() http.Handler {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(m)
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
+
+// LoadPricingFromConfig 从通用配置 map 中解析 metrics.pricing 价目表，用于构建 NewMetrics 的入参
+// YAML 形如：
+//
+//	metrics:
+//	  pricing:
+//	    - model: gpt-4o
+//	      input_usd_per_1k: 0.005
+//	      output_usd_per_1k: 0.015
+func LoadPricingFromConfig(cfg map[string]interface{}) (map[string]ModelPricing, error) {
+	raw := config.GetRaw(cfg, "metrics.pricing")
+	if raw == nil {
+		return nil, nil
+	}
+
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("metrics.pricing must be a list")
+	}
+
+	pricing := make(map[string]ModelPricing, len(list))
+	for i, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("metrics.pricing[%d] must be an object", i)
+		}
+		model, _ := m["model"].(string)
+		if model == "" {
+			return nil, fmt.Errorf("metrics.pricing[%d] missing model", i)
+		}
+		pricing[model] = ModelPricing{
+			InputUSDPer1K:  floatField(m, "input_usd_per_1k"),
+			OutputUSDPer1K: floatField(m, "output_usd_per_1k"),
+		}
+	}
+
+	return pricing, nil
+}
+
+func floatField(m map[string]interface{}, key string) float64 {
+	switch v := m[key].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	default:
+		return 0
+	}
+}
+
+// Budget 是一个按自然日滚动的用量/成本上限：Allow 在发起请求前检查当日额度是否已耗尽，
+// Record 在调用结束后累加实际花费，两者都按 UTC 日期分桶，跨天自动清零
+type Budget struct {
+	mu sync.Mutex
+
+	dailyUSDLimit   float64
+	dailyTokenLimit int64
+
+	day         string
+	spentUSD    float64
+	spentTokens int64
+}
+
+// NewBudget 创建一个每日额度守卫，limit <= 0 表示该维度不限制
+func NewBudget(dailyUSDLimit float64, dailyTokenLimit int64) *Budget {
+	return &Budget{
+		dailyUSDLimit:   dailyUSDLimit,
+		dailyTokenLimit: dailyTokenLimit,
+	}
+}
+
+func (b *Budget) resetIfNewDayLocked() {
+	today := time.Now().UTC().Format("2006-01-02")
+	if b.day != today {
+		b.day = today
+		b.spentUSD = 0
+		b.spentTokens = 0
+	}
+}
+
+// Allow 报告当日额度是否还有余量；err 非 nil 时说明已超限，调用方应当直接拒绝这次请求，
+// 不应该真的发出上游 HTTP 调用
+func (b *Budget) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.resetIfNewDayLocked()
+
+	if b.dailyUSDLimit > 0 && b.spentUSD >= b.dailyUSDLimit {
+		return fmt.Errorf("daily budget exceeded: spent $%.4f of $%.4f limit", b.spentUSD, b.dailyUSDLimit)
+	}
+	if b.dailyTokenLimit > 0 && b.spentTokens >= b.dailyTokenLimit {
+		return fmt.Errorf("daily token budget exceeded: spent %d of %d limit", b.spentTokens, b.dailyTokenLimit)
+	}
+	return nil
+}
+
+// Record 累加一次调用实际花费的美元成本和 token 数，跨天会先清零再累加
+func (b *Budget) Record(costUSD float64, tokens int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.resetIfNewDayLocked()
+	b.spentUSD += costUSD
+	b.spentTokens += tokens
+}
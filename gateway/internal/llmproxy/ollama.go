@@ -0,0 +1,258 @@
+package llmproxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OllamaProvider 适配本地 Ollama 的 `/api/chat` 协议：请求/响应结构和 OpenAI 类似，
+// 但流式响应是逐行 NDJSON（每行一个完整 JSON 对象），不是 SSE 的 `data: ` 前缀行
+type OllamaProvider struct {
+	name       string
+	model      string
+	endpoint   string
+	httpClient *http.Client
+}
+
+// NewOllamaProvider 创建 Ollama Provider，endpoint 留空时默认本机 11434 端口
+func NewOllamaProvider(name, endpoint, model string, timeout time.Duration) *OllamaProvider {
+	if endpoint == "" {
+		endpoint = "http://localhost:11434"
+	}
+	if timeout == 0 {
+		timeout = 60 * time.Second
+	}
+	return &OllamaProvider{
+		name:       name,
+		model:      model,
+		endpoint:   strings.TrimRight(endpoint, "/"),
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+func (p *OllamaProvider) Name() string { return p.name }
+
+func (p *OllamaProvider) Supports(model string) bool { return p.model == "" || p.model == model }
+
+// ollamaMessage 是 /api/chat 请求消息格式，字段和 OpenAI 的 ChatMessageHTTP 同名但不支持多模态 content parts
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ollamaChatRequest /api/chat 请求体
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Options  ollamaOptions   `json:"options,omitempty"`
+}
+
+// ollamaOptions 对应 Ollama 的生成参数，命名沿用其 REST API 文档
+type ollamaOptions struct {
+	Temperature *float32 `json:"temperature,omitempty"`
+	TopP        *float32 `json:"top_p,omitempty"`
+}
+
+// ollamaChatResponse /api/chat 响应体，流式时每行一个这样的对象，done=true 标志最后一行带完整用量统计
+type ollamaChatResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	PromptEvalCount int32         `json:"prompt_eval_count"`
+	EvalCount       int32         `json:"eval_count"`
+}
+
+func (p *OllamaProvider) toOllamaRequest(req *ChatCompletionHTTPRequest, stream bool) *ollamaChatRequest {
+	out := &ollamaChatRequest{
+		Model:  p.model,
+		Stream: stream,
+		Options: ollamaOptions{
+			Temperature: req.Temperature,
+			TopP:        req.TopP,
+		},
+	}
+	for _, msg := range req.Messages {
+		content, ok := msg.Content.(string)
+		if !ok {
+			content = fmt.Sprintf("%v", msg.Content)
+		}
+		out.Messages = append(out.Messages, ollamaMessage{Role: msg.Role, Content: content})
+	}
+	return out
+}
+
+func fromOllamaResponse(resp *ollamaChatResponse) *ChatCompletionHTTPResponse {
+	return &ChatCompletionHTTPResponse{
+		Object: "chat.completion",
+		Model:  resp.Model,
+		Choices: []ChoiceHTTP{
+			{
+				Index:        0,
+				Message:      ChatMessageHTTP{Role: "assistant", Content: resp.Message.Content},
+				FinishReason: "stop",
+			},
+		},
+		Usage: UsageHTTP{
+			PromptTokens:     resp.PromptEvalCount,
+			CompletionTokens: resp.EvalCount,
+			TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+		},
+	}
+}
+
+func (p *OllamaProvider) ChatCompletion(ctx context.Context, req *ChatCompletionHTTPRequest) (*ChatCompletionHTTPResponse, error) {
+	jsonBytes, err := json.Marshal(p.toOllamaRequest(req, false))
+	if err != nil {
+		return nil, fmt.Errorf("marshal ollama request failed: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+"/api/chat", bytes.NewReader(jsonBytes))
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("do request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response body failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var result ollamaChatResponse
+	if err := json.Unmarshal(bodyBytes, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal response failed: %w, body: %s", err, string(bodyBytes))
+	}
+
+	return fromOllamaResponse(&result), nil
+}
+
+// ChatCompletionStream 逐行读取 NDJSON，每行独立 Unmarshal 后转换为一个 chunk 回调给 handler
+func (p *OllamaProvider) ChatCompletionStream(ctx context.Context, req *ChatCompletionHTTPRequest, handler StreamChunkHandler) error {
+	jsonBytes, err := json.Marshal(p.toOllamaRequest(req, true))
+	if err != nil {
+		return fmt.Errorf("marshal ollama request failed: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+"/api/chat", bytes.NewReader(jsonBytes))
+	if err != nil {
+		return fmt.Errorf("create request failed: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("do request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		line, err := reader.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			var chunk ollamaChatResponse
+			if unmarshalErr := json.Unmarshal(line, &chunk); unmarshalErr != nil {
+				return fmt.Errorf("unmarshal stream chunk failed: %w, line: %s", unmarshalErr, string(line))
+			}
+
+			finishReason := ""
+			if chunk.Done {
+				finishReason = "stop"
+			}
+			handleErr := handler(&ChatCompletionChunkHTTP{
+				Object: "chat.completion.chunk",
+				Model:  chunk.Model,
+				Choices: []StreamChoiceHTTP{
+					{
+						Index:        0,
+						Delta:        ChatMessageDeltaHTTP{Role: "assistant", Content: chunk.Message.Content},
+						FinishReason: finishReason,
+					},
+				},
+			})
+			if handleErr != nil {
+				return fmt.Errorf("handle chunk failed: %w", handleErr)
+			}
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("read stream failed: %w", err)
+		}
+	}
+}
+
+// Embedding 调用 Ollama 的 /api/embeddings，每次只接受一个输入，多输入时逐个请求后拼接
+func (p *OllamaProvider) Embedding(ctx context.Context, input []string) (*EmbeddingHTTPResponse, error) {
+	result := &EmbeddingHTTPResponse{Object: "list"}
+
+	for i, text := range input {
+		jsonBytes, err := json.Marshal(map[string]string{"model": p.model, "prompt": text})
+		if err != nil {
+			return nil, fmt.Errorf("marshal ollama embedding request failed: %w", err)
+		}
+
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+"/api/embeddings", bytes.NewReader(jsonBytes))
+		if err != nil {
+			return nil, fmt.Errorf("create request failed: %w", err)
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+
+		resp, err := p.httpClient.Do(httpReq)
+		if err != nil {
+			return nil, fmt.Errorf("do request failed: %w", err)
+		}
+
+		var parsed struct {
+			Embedding []float32 `json:"embedding"`
+		}
+		decodeErr := json.NewDecoder(resp.Body).Decode(&parsed)
+		resp.Body.Close()
+		if decodeErr != nil {
+			return nil, fmt.Errorf("decode embedding response failed: %w", decodeErr)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		}
+
+		result.Data = append(result.Data, EmbeddingDataHTTP{Index: int32(i), Embedding: parsed.Embedding})
+	}
+
+	result.Model = p.model
+	return result, nil
+}
+
+// Close 关闭空闲连接
+func (p *OllamaProvider) Close() error {
+	p.httpClient.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,245 @@
+package llmproxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"bot_agent/gateway/internal/logger"
+)
+
+// ProviderType 标识一个部署使用的上游协议
+type ProviderType string
+
+const (
+	ProviderAzureOpenAI      ProviderType = "azure_openai"
+	ProviderOpenAI           ProviderType = "openai"
+	ProviderAnthropic        ProviderType = "anthropic"
+	ProviderDeepSeek         ProviderType = "deepseek"
+	ProviderZhipuAI          ProviderType = "zhipuai"           // 智谱 GLM，协议兼容 OpenAI 但鉴权是现签 JWT
+	ProviderOllama           ProviderType = "ollama"            // 本地 Ollama，/api/chat + NDJSON 流式
+	ProviderOpenAICompatible ProviderType = "openai_compatible" // vLLM 等本地 OpenAI 兼容端点
+)
+
+// Provider 是所有上游 LLM 厂商客户端需要实现的统一接口
+// Router 只面向 Provider 编程，不感知具体厂商的请求/响应格式差异
+type Provider interface {
+	// Name 返回该 Provider 实例的名称（通常等于部署名），用于日志和指标打点
+	Name() string
+	// ChatCompletion 非流式对话补全
+	ChatCompletion(ctx context.Context, req *ChatCompletionHTTPRequest) (*ChatCompletionHTTPResponse, error)
+	// ChatCompletionStream 流式对话补全
+	ChatCompletionStream(ctx context.Context, req *ChatCompletionHTTPRequest, handler StreamChunkHandler) error
+	// Embedding 文本向量化
+	Embedding(ctx context.Context, input []string) (*EmbeddingHTTPResponse, error)
+	// Supports 判断该 Provider 是否能处理指定的模型名
+	Supports(model string) bool
+}
+
+// AzureOpenAIProvider 基于现有 LLMClient 实现 Azure OpenAI 协议
+type AzureOpenAIProvider struct {
+	name         string
+	model        string
+	deploymentID string
+	apiVersion   string
+	client       *LLMClient
+}
+
+// NewAzureOpenAIProvider 创建 Azure OpenAI Provider，复用 LLMClient 的部署路径拼接方式
+func NewAzureOpenAIProvider(name string, cfg LLMProxyConfig, deploymentID, apiVersion, model string) *AzureOpenAIProvider {
+	return &AzureOpenAIProvider{
+		name:         name,
+		model:        model,
+		deploymentID: deploymentID,
+		apiVersion:   apiVersion,
+		client:       NewLLMClient(cfg),
+	}
+}
+
+func (p *AzureOpenAIProvider) Name() string { return p.name }
+
+func (p *AzureOpenAIProvider) Supports(model string) bool { return p.model == "" || p.model == model }
+
+func (p *AzureOpenAIProvider) ChatCompletion(ctx context.Context, req *ChatCompletionHTTPRequest) (*ChatCompletionHTTPResponse, error) {
+	return p.client.ChatCompletion(ctx, p.deploymentID, p.apiVersion, req)
+}
+
+func (p *AzureOpenAIProvider) ChatCompletionStream(ctx context.Context, req *ChatCompletionHTTPRequest, handler StreamChunkHandler) error {
+	return p.client.ChatCompletionStream(ctx, p.deploymentID, p.apiVersion, req, handler)
+}
+
+func (p *AzureOpenAIProvider) Embedding(ctx context.Context, input []string) (*EmbeddingHTTPResponse, error) {
+	return p.client.GetEmbedding(ctx, p.deploymentID, p.apiVersion, input)
+}
+
+// Close 关闭底层 LLMClient 持有的连接
+func (p *AzureOpenAIProvider) Close() error {
+	return p.client.Close()
+}
+
+// OpenAICompatibleProvider 适配标准 `/v1/chat/completions` 协议的厂商：OpenAI、DeepSeek、vLLM、Ollama 等
+// 与 AzureOpenAIProvider 的区别在于 URL 不拼接 deployments/{id} 段，而是直接使用 endpoint + 固定路径
+type OpenAICompatibleProvider struct {
+	name       string
+	model      string
+	endpoint   string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewOpenAICompatibleProvider 创建 OpenAI 兼容协议 Provider
+func NewOpenAICompatibleProvider(name, endpoint, apiKey, model string, timeout time.Duration) *OpenAICompatibleProvider {
+	if timeout == 0 {
+		timeout = 60 * time.Second
+	}
+	return &OpenAICompatibleProvider{
+		name:       name,
+		model:      model,
+		endpoint:   strings.TrimRight(endpoint, "/"),
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+func (p *OpenAICompatibleProvider) Name() string { return p.name }
+
+func (p *OpenAICompatibleProvider) Supports(model string) bool {
+	return p.model == "" || p.model == model
+}
+
+func (p *OpenAICompatibleProvider) doJSON(ctx context.Context, path string, body interface{}, out interface{}) error {
+	jsonBytes, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal request body failed: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+path, bytes.NewReader(jsonBytes))
+	if err != nil {
+		return fmt.Errorf("create request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response body failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(respBytes))
+	}
+
+	if err := json.Unmarshal(respBytes, out); err != nil {
+		return fmt.Errorf("unmarshal response failed: %w, body: %s", err, string(respBytes))
+	}
+	return nil
+}
+
+func (p *OpenAICompatibleProvider) ChatCompletion(ctx context.Context, req *ChatCompletionHTTPRequest) (*ChatCompletionHTTPResponse, error) {
+	req.Stream = false
+	var result ChatCompletionHTTPResponse
+	if err := p.doJSON(ctx, "/v1/chat/completions", req, &result); err != nil {
+		return nil, err
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("%s API error: %s (type: %s, code: %s)", p.name, result.Error.Message, result.Error.Type, result.Error.Code)
+	}
+	return &result, nil
+}
+
+func (p *OpenAICompatibleProvider) ChatCompletionStream(ctx context.Context, req *ChatCompletionHTTPRequest, handler StreamChunkHandler) error {
+	req.Stream = true
+
+	jsonBytes, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("marshal request body failed: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+"/v1/chat/completions", bytes.NewReader(jsonBytes))
+	if err != nil {
+		return fmt.Errorf("create request failed: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("do request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("read stream failed: %w", err)
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" || !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		data := strings.TrimPrefix(line, "data: ")
+		if data == "[DONE]" {
+			return nil
+		}
+
+		var chunk ChatCompletionChunkHTTP
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			logger.Warn("unmarshal stream chunk failed: %v, data: %s", err, data)
+			continue
+		}
+
+		if err := handler(&chunk); err != nil {
+			return fmt.Errorf("handle chunk failed: %w", err)
+		}
+	}
+}
+
+func (p *OpenAICompatibleProvider) Embedding(ctx context.Context, input []string) (*EmbeddingHTTPResponse, error) {
+	var result EmbeddingHTTPResponse
+	if err := p.doJSON(ctx, "/v1/embeddings", &EmbeddingHTTPRequest{Input: input}, &result); err != nil {
+		return nil, err
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("%s embedding API error: %s (type: %s, code: %s)", p.name, result.Error.Message, result.Error.Type, result.Error.Code)
+	}
+	return &result, nil
+}
+
+// Close 关闭空闲连接
+func (p *OpenAICompatibleProvider) Close() error {
+	p.httpClient.CloseIdleConnections()
+	return nil
+}
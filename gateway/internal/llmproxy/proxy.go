@@ -20,6 +20,14 @@ type LLMProxyConfig struct {
 	APIKey     string        // API Key
 	Timeout    time.Duration // 请求超时时间
 	MaxRetries int           // 最大重试次数
+
+	// Model 是这个部署对应的模型名，仅用于 Metrics/Budget 按模型做用量和成本统计，不影响请求本身
+	Model string
+	// Metrics 不为 nil 时，每次 ChatCompletion/ChatCompletionStream/GetEmbedding 调用都会上报用量、
+	// 延迟和成本指标，见 metrics.go
+	Metrics *Metrics
+	// Budget 不为 nil 时，调用前先检查当日额度，超限直接拒绝请求，不会发出 HTTP 调用
+	Budget *Budget
 }
 
 // LLMClient LLM API 客户端
@@ -64,6 +72,13 @@ type ChatCompletionHTTPRequest struct {
 	Tools               []ToolHTTP        `json:"tools,omitempty"`
 	ToolChoice          interface{}       `json:"tool_choice,omitempty"` // string 或 object
 	Stream              bool              `json:"stream,omitempty"`
+	StreamOptions       *StreamOptionsHTTP `json:"stream_options,omitempty"`
+}
+
+// StreamOptionsHTTP 控制流式请求的附加行为，目前只有 include_usage：开启后上游会在流结束前
+// 额外下发一个不带 choices、只带 usage 字段的 chunk，ChatCompletionStream 会透传给调用方
+type StreamOptionsHTTP struct {
+	IncludeUsage bool `json:"include_usage,omitempty"`
 }
 
 // ResponseFormat 响应格式
@@ -170,8 +185,25 @@ type StreamChoiceHTTP struct {
 
 // ChatMessageDeltaHTTP 增量消息
 type ChatMessageDeltaHTTP struct {
-	Role    string `json:"role,omitempty"`
-	Content string `json:"content,omitempty"`
+	Role      string              `json:"role,omitempty"`
+	Content   string              `json:"content,omitempty"`
+	ToolCalls []ToolCallDeltaHTTP `json:"tool_calls,omitempty"`
+}
+
+// ToolCallDeltaHTTP 是流式响应里 tool_calls 的增量分片：Index 在整个流里标识同一个 tool call，
+// id/type/function.name 通常只出现在该 tool call 的第一个分片，function.arguments 则逐字符/逐 token
+// 分片到达，调用方需要按 Index 把多个分片拼接成完整的 ToolCallHTTP，见 toolCallAssembler
+type ToolCallDeltaHTTP struct {
+	Index    int                   `json:"index"`
+	ID       string                `json:"id,omitempty"`
+	Type     string                `json:"type,omitempty"`
+	Function FunctionCallDeltaHTTP `json:"function,omitempty"`
+}
+
+// FunctionCallDeltaHTTP 是 tool_calls 分片里 function 字段的增量部分
+type FunctionCallDeltaHTTP struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
 }
 
 // EmbeddingHTTPRequest Embedding 请求
@@ -231,52 +263,143 @@ func (c *LLMClient) doRequest(ctx context.Context, method, url string, body inte
 	return resp, nil
 }
 
+// doWithRetry 是 doRequest 加一层重试退避：网络错误、429、5xx 按 MaxRetries 重试，
+// 优先遵守响应的 Retry-After，否则指数退避+抖动；非幂等失败（4xx 等）直接返回不重试。
+// 返回值里的 body 已经读取完毕并关闭了底层连接，调用方不需要也不能再 Close
+func (c *LLMClient) doWithRetry(ctx context.Context, method, url string, body interface{}) (statusCode int, respBody []byte, header http.Header, err error) {
+	attempts := c.config.MaxRetries + 1
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		resp, doErr := c.doRequest(ctx, method, url, body)
+		if doErr != nil {
+			lastErr = doErr
+			if attempt == attempts-1 || !isRetryableErr(doErr) {
+				return 0, nil, nil, doErr
+			}
+			logger.Warnw("llm request failed, retrying", "attempt", attempt, "error", doErr)
+			if sleepErr := sleepCtx(ctx, backoffWithJitter(attempt)); sleepErr != nil {
+				return 0, nil, nil, sleepErr
+			}
+			continue
+		}
+
+		bodyBytes, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if readErr != nil {
+			return 0, nil, nil, fmt.Errorf("read response body failed: %w", readErr)
+		}
+
+		if isRetryableStatus(resp.StatusCode) && attempt < attempts-1 {
+			lastErr = fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
+			wait := retryDelay(resp.Header.Get("Retry-After"), attempt)
+			logger.Warnw("llm request got retryable status, backing off", "status", resp.StatusCode, "attempt", attempt, "wait", wait)
+			if sleepErr := sleepCtx(ctx, wait); sleepErr != nil {
+				return 0, nil, nil, sleepErr
+			}
+			continue
+		}
+
+		return resp.StatusCode, bodyBytes, resp.Header, nil
+	}
+
+	return 0, nil, nil, lastErr
+}
+
 // ChatCompletion 非流式对话请求
-func (c *LLMClient) ChatCompletion(ctx context.Context, deploymentID, apiVersion string, req *ChatCompletionHTTPRequest) (*ChatCompletionHTTPResponse, error) {
+func (c *LLMClient) ChatCompletion(ctx context.Context, deploymentID, apiVersion string, req *ChatCompletionHTTPRequest) (result *ChatCompletionHTTPResponse, err error) {
+	if c.config.Budget != nil {
+		if err := c.config.Budget.Allow(); err != nil {
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	defer func() {
+		if c.config.Metrics == nil {
+			return
+		}
+		var usage *UsageHTTP
+		if result != nil {
+			usage = &result.Usage
+		}
+		c.config.Metrics.RecordChatCompletion(deploymentID, c.config.Model, usage, time.Since(start), err)
+		if result != nil && c.config.Budget != nil {
+			cost := c.config.Metrics.pricingFor(c.config.Model).cost(result.Usage.PromptTokens, result.Usage.CompletionTokens)
+			c.config.Budget.Record(cost, int64(result.Usage.TotalTokens))
+		}
+	}()
+
 	req.Stream = false
 
 	url := c.buildURL(deploymentID, apiVersion, "v1/chat/completions")
 
-	resp, err := c.doRequest(ctx, http.MethodPost, url, req)
+	statusCode, bodyBytes, _, err := c.doWithRetry(ctx, http.MethodPost, url, req)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read response body failed: %w", err)
-	}
 
-	logger.Debug("LLM response status: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	logger.Debug("LLM response status: %d, body: %s", statusCode, string(bodyBytes))
 
-	var result ChatCompletionHTTPResponse
-	if err := json.Unmarshal(bodyBytes, &result); err != nil {
+	var resp ChatCompletionHTTPResponse
+	if err := json.Unmarshal(bodyBytes, &resp); err != nil {
 		return nil, fmt.Errorf("unmarshal response failed: %w, body: %s", err, string(bodyBytes))
 	}
 
-	if result.Error != nil {
+	if resp.Error != nil {
 		return nil, fmt.Errorf("LLM API error: %s (type: %s, code: %s)",
-			result.Error.Message, result.Error.Type, result.Error.Code)
+			resp.Error.Message, resp.Error.Type, resp.Error.Code)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d, body: %s", statusCode, string(bodyBytes))
 	}
 
-	return &result, nil
+	return &resp, nil
 }
 
 // StreamChunkHandler 流式响应处理回调
 type StreamChunkHandler func(chunk *ChatCompletionChunkHTTP) error
 
 // ChatCompletionStream 流式对话请求
-func (c *LLMClient) ChatCompletionStream(ctx context.Context, deploymentID, apiVersion string, req *ChatCompletionHTTPRequest, handler StreamChunkHandler) error {
+func (c *LLMClient) ChatCompletionStream(ctx context.Context, deploymentID, apiVersion string, req *ChatCompletionHTTPRequest, handler StreamChunkHandler) (err error) {
+	if c.config.Budget != nil {
+		if err := c.config.Budget.Allow(); err != nil {
+			return err
+		}
+	}
+
+	// Usage 只会出现在 [DONE] 之前的最后一个 chunk 里，且要靠 stream_options.include_usage 开启，
+	// 配了 Metrics 就自动打开这个开关，不需要调用方每次手动设置
+	if c.config.Metrics != nil {
+		if req.StreamOptions == nil {
+			req.StreamOptions = &StreamOptionsHTTP{}
+		}
+		req.StreamOptions.IncludeUsage = true
+	}
+
+	start := time.Now()
+	var lastUsage *UsageHTTP
+	defer func() {
+		if c.config.Metrics == nil {
+			return
+		}
+		c.config.Metrics.RecordChatCompletion(deploymentID, c.config.Model, lastUsage, time.Since(start), err)
+		if lastUsage != nil && c.config.Budget != nil {
+			cost := c.config.Metrics.pricingFor(c.config.Model).cost(lastUsage.PromptTokens, lastUsage.CompletionTokens)
+			c.config.Budget.Record(cost, int64(lastUsage.TotalTokens))
+		}
+	}()
+
 	req.Stream = true
 
 	url := c.buildURL(deploymentID, apiVersion, "chat/completions")
 
-	// 流式请求使用独立的 HTTP 客户端，不设置超时
+	// 流式请求使用独立的 HTTP 客户端，不设置 http.Client.Timeout（那会在拿到首字节前就可能掐断
+	// 正在传输的 SSE 流），超时改由下面的 deadlineTimer 控制，可以和 ctx 取消一起生效
 	httpClient := &http.Client{}
 
 	var bodyReader io.Reader
@@ -306,17 +429,40 @@ func (c *LLMClient) ChatCompletionStream(ctx context.Context, deploymentID, apiV
 		return fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
 	}
 
-	// 解析 SSE 流
-	reader := bufio.NewReader(resp.Body)
-	for {
+	// reader.ReadString 是阻塞调用，没法直接 select ctx.Done()/超时通道；用一个哨兵 goroutine
+	// 监听两者，谁先触发就关闭 resp.Body 来解除阻塞的 Read，读循环里再根据 ctx.Err()/deadline
+	// 区分是取消还是超时
+	dl := newDeadlineTimer()
+	if c.config.Timeout > 0 {
+		dl.set(c.config.Timeout)
+	}
+	defer dl.stop()
+
+	watchDone := make(chan struct{})
+	defer close(watchDone)
+	go func() {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
-		default:
+			resp.Body.Close()
+		case <-dl.cancel():
+			resp.Body.Close()
+		case <-watchDone:
 		}
+	}()
 
+	// 解析 SSE 流
+	reader := bufio.NewReader(resp.Body)
+	for {
 		line, err := reader.ReadString('\n')
 		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			select {
+			case <-dl.cancel():
+				return fmt.Errorf("stream read deadline exceeded after %s", c.config.Timeout)
+			default:
+			}
 			if err == io.EOF {
 				return nil
 			}
@@ -343,6 +489,9 @@ func (c *LLMClient) ChatCompletionStream(ctx context.Context, deploymentID, apiV
 			logger.Warn("unmarshal stream chunk failed: %v, data: %s", err, data)
 			continue
 		}
+		if chunk.Usage != nil {
+			lastUsage = chunk.Usage
+		}
 
 		if err := handler(&chunk); err != nil {
 			return fmt.Errorf("handle chunk failed: %w", err)
@@ -351,41 +500,57 @@ func (c *LLMClient) ChatCompletionStream(ctx context.Context, deploymentID, apiV
 }
 
 // GetEmbedding 获取 Embedding 向量
-func (c *LLMClient) GetEmbedding(ctx context.Context, deploymentID, apiVersion string, input []string) (*EmbeddingHTTPResponse, error) {
+func (c *LLMClient) GetEmbedding(ctx context.Context, deploymentID, apiVersion string, input []string) (result *EmbeddingHTTPResponse, err error) {
+	if c.config.Budget != nil {
+		if err := c.config.Budget.Allow(); err != nil {
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	defer func() {
+		if c.config.Metrics == nil {
+			return
+		}
+		var usage *UsageHTTP
+		if result != nil {
+			usage = &result.Usage
+		}
+		c.config.Metrics.RecordEmbedding(deploymentID, c.config.Model, usage, time.Since(start), err)
+		if result != nil && c.config.Budget != nil {
+			cost := c.config.Metrics.pricingFor(c.config.Model).cost(result.Usage.PromptTokens, result.Usage.CompletionTokens)
+			c.config.Budget.Record(cost, int64(result.Usage.TotalTokens))
+		}
+	}()
+
 	req := &EmbeddingHTTPRequest{
 		Input: input,
 	}
 
 	url := c.buildURL(deploymentID, apiVersion, "embeddings")
 
-	resp, err := c.doRequest(ctx, http.MethodPost, url, req)
+	statusCode, bodyBytes, _, err := c.doWithRetry(ctx, http.MethodPost, url, req)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read response body failed: %w", err)
-	}
 
-	logger.Debug("Embedding response status: %d, body length: %d", resp.StatusCode, len(bodyBytes))
+	logger.Debug("Embedding response status: %d, body length: %d", statusCode, len(bodyBytes))
 
-	var result EmbeddingHTTPResponse
-	if err := json.Unmarshal(bodyBytes, &result); err != nil {
+	var resp EmbeddingHTTPResponse
+	if err := json.Unmarshal(bodyBytes, &resp); err != nil {
 		return nil, fmt.Errorf("unmarshal response failed: %w", err)
 	}
 
-	if result.Error != nil {
+	if resp.Error != nil {
 		return nil, fmt.Errorf("Embedding API error: %s (type: %s, code: %s)",
-			result.Error.Message, result.Error.Type, result.Error.Code)
+			resp.Error.Message, resp.Error.Type, resp.Error.Code)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", statusCode)
 	}
 
-	return &result, nil
+	return &resp, nil
 }
 
 // Close 关闭客户端
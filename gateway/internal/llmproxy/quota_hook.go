@@ -0,0 +1,111 @@
+package llmproxy
+
+import (
+	"context"
+
+	"bot_agent/gateway/internal/quota"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// quotaKeyFromContext 从 gRPC 请求元数据中提取配额维度的 key：优先使用 API Key，否则退回 x-tenant-id
+// 两者都没有时返回空字符串，配额管理器会把空 key 当作一个独立的匿名调用方统一限流
+func quotaKeyFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if vals := md.Get("x-api-key"); len(vals) > 0 {
+		return vals[0]
+	}
+	if vals := md.Get("x-tenant-id"); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+// estimateRequestTokens 粗略估算一次对话请求的 token 数（按字符数/4 近似），用于 Reserve 阶段预扣 TPM
+// 真实消耗以响应中的 Usage 字段为准，在 Commit 阶段回填差额
+func estimateRequestTokens(req *ChatCompletionHTTPRequest) int32 {
+	chars := 0
+	for _, msg := range req.Messages {
+		if s, ok := msg.Content.(string); ok {
+			chars += len(s)
+		}
+	}
+	if req.MaxCompletionTokens != nil {
+		chars += int(*req.MaxCompletionTokens) * 4
+	}
+	tokens := chars / 4
+	if tokens <= 0 {
+		tokens = 1
+	}
+	return int32(tokens)
+}
+
+// quotaExceededStatus 把 Manager 返回的配额错误转换为带 retry-after 提示的 gRPC ResourceExhausted 状态
+func quotaExceededStatus(err *quota.QuotaExceededError) error {
+	st := status.New(codes.ResourceExhausted, err.Error())
+	return st.Err()
+}
+
+// reserveQuota 在调用上游前做配额预检，未配置 Manager 时直接放行
+func (s *LLMProxyService) reserveQuota(ctx context.Context, model string, estimatedTokens int32) (*quota.Reservation, error) {
+	if s.quotaMgr == nil {
+		return nil, nil
+	}
+
+	key := quotaKeyFromContext(ctx)
+	res, err := s.quotaMgr.Reserve(key, estimatedTokens, model)
+	if err != nil {
+		if qe, ok := err.(*quota.QuotaExceededError); ok {
+			return nil, quotaExceededStatus(qe)
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+// commitQuota 用实际 Usage 核销一次预扣
+func (s *LLMProxyService) commitQuota(res *quota.Reservation, model string, promptTokens, completionTokens int32) {
+	if s.quotaMgr == nil || res == nil {
+		return
+	}
+	s.quotaMgr.Commit(res, model, promptTokens, completionTokens)
+}
+
+// rollbackQuota 归还一次未完成请求预扣的配额（如流式调用被客户端提前取消）
+func (s *LLMProxyService) rollbackQuota(res *quota.Reservation) {
+	if s.quotaMgr == nil || res == nil {
+		return
+	}
+	s.quotaMgr.Rollback(res)
+}
+
+// SetQuotaManager 设置配额管理器；设置后 ChatCompletion/ChatCompletionStream/GetEmbedding 会做 RPM/TPM/月度预算校验
+func (s *LLMProxyService) SetQuotaManager(mgr *quota.Manager) {
+	s.quotaMgr = mgr
+}
+
+// AdminGetQuota 查询某个 key 当前生效的限额，供运行时管理接口调用
+//
+// 注意：这两个 Admin* 方法目前只是 Go 层 API，还没有对外的 gRPC 管理 RPC——需要先在 LLMProxyService
+// 的 proto 定义里加上 GetQuota/SetQuota 方法、重新生成 pb 代码，再在这里转调。这是有意没做，不是漏做：
+// 留到下一次改 proto 的改动里一起做，避免这次改动混进生成代码的变更
+func (s *LLMProxyService) AdminGetQuota(key string) (quota.Limits, bool) {
+	if s.quotaMgr == nil {
+		return quota.Limits{}, false
+	}
+	return s.quotaMgr.GetLimits(key), true
+}
+
+// AdminSetQuota 运行时更新某个 key 的限额
+func (s *LLMProxyService) AdminSetQuota(key string, lim quota.Limits) bool {
+	if s.quotaMgr == nil {
+		return false
+	}
+	s.quotaMgr.SetLimits(key, lim)
+	return true
+}
@@ -0,0 +1,89 @@
+package llmproxy
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	retryBaseBackoff = 200 * time.Millisecond
+	retryMaxBackoff  = 5 * time.Second
+)
+
+// isRetryableStatus 判断响应状态码是否值得退避重试：429（限流）和所有 5xx（上游故障），
+// 这两类通常是瞬时的，重试大概率能成功；4xx 里的其它状态码是调用方的错，重试没有意义
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// isRetryableErr 判断 doRequest 返回的错误是否值得重试：网络超时/连接类错误可重试，
+// ctx 被调用方主动取消（DeadlineExceeded/Canceled）不重试，重试也无法绕过调用方的取消意图
+func isRetryableErr(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return false
+}
+
+// backoffWithJitter 按 attempt（从 0 开始）计算指数退避时长，并加上半幅度的随机抖动，
+// 避免大量并发请求在同一时刻集体重试造成惊群
+func backoffWithJitter(attempt int) time.Duration {
+	d := retryBaseBackoff * time.Duration(int64(1)<<uint(attempt))
+	if d <= 0 || d > retryMaxBackoff {
+		d = retryMaxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(d)/2 + 1))
+	return d/2 + jitter
+}
+
+// parseRetryAfter 解析 Retry-After 响应头，支持 RFC 7231 的两种形式：秒数或 HTTP 日期
+func parseRetryAfter(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(header); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// retryDelay 优先使用服务端下发的 Retry-After，没有的话退回指数退避+抖动
+func retryDelay(retryAfterHeader string, attempt int) time.Duration {
+	if d, ok := parseRetryAfter(retryAfterHeader); ok {
+		return d
+	}
+	return backoffWithJitter(attempt)
+}
+
+// sleepCtx 等待 d 或 ctx 被取消，哪个先到就返回，取消时把 ctx.Err() 透传给调用方
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,394 @@
+package llmproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"bot_agent/gateway/internal/config"
+	"bot_agent/gateway/internal/logger"
+)
+
+// DeploymentConfig 对应 YAML 中 llm.deployments 表的一行
+type DeploymentConfig struct {
+	// LogicalName 是客户端请求中使用的 deployment_id，多个副本可以共享同一个 LogicalName 做加权轮询
+	LogicalName  string
+	ProviderType ProviderType
+	Endpoint     string
+	APIKey       string
+	APIVersion   string
+	Model        string
+	Weight       int
+	// Fallback 指向另一个 LogicalName，当本组所有副本都熔断时尝试转移过去
+	Fallback string
+	Timeout  time.Duration
+	// FailureThreshold/Cooldown 控制熔断器参数，0 表示使用默认值
+	FailureThreshold int
+	Cooldown         time.Duration
+
+	// CacheEnabled 对应 YAML 里的 cache_enabled，决定这个部署是否接入下面的 CacheStore；
+	// LoadDeploymentsFromConfig 只负责解析这个开关和下面几个参数，实际的 CacheStore/CacheMetrics
+	// 实例由 main.go 按 llm.cache_store.* 统一构建后，在开关为 true 的部署上挂上去
+	CacheEnabled bool
+	// CacheStore 不为 nil 时，这个部署的 Provider 会被 CachingClient 包一层语义缓存（见 caching_client.go）；
+	// 多个部署可以共享同一个 MemoryCacheStore/RedisCacheStore 实例
+	CacheStore CacheStore
+	// CacheEmbedDeployment 是计算缓存 embedding 向量时路由到的 deployment_id，留空则复用本部署自身的 LogicalName
+	CacheEmbedDeployment string
+	// CacheThreshold/CacheTTL 见 NewCachingClient，零值使用其默认值
+	CacheThreshold float32
+	CacheTTL       time.Duration
+	// CacheMetrics 不为 nil 时上报这个部署的缓存命中率/节省成本指标，多个部署可以共享同一个实例
+	CacheMetrics *CacheMetrics
+
+	// Metrics 不为 nil 时，这个部署每次调用都会上报用量/延迟/成本指标（见 metrics.go），
+	// 多个部署通常共享同一个 Metrics 实例，这样才能在一张 Prometheus 表里按 deployment 标签区分
+	Metrics *Metrics
+	// Budget 不为 nil 时，调用前先检查共享的每日额度，超限直接拒绝请求；同样通常多个部署共享一个实例
+	Budget *Budget
+}
+
+// replica 是 Router 内部对一个部署副本的封装：具体 Provider + 权重 + 独立熔断器
+type replica struct {
+	config  DeploymentConfig
+	name    string
+	weight  int
+	breaker *circuitBreaker
+}
+
+// group 是同一个 LogicalName 下的所有副本，以及轮询游标
+type group struct {
+	replicas []*replica
+	cursor   int // 当前轮询到的权重展开下标
+	expanded []int
+}
+
+// Router 负责把客户端传入的 deployment_id 路由到具体 Provider，并处理加权轮询、故障转移和熔断
+type Router struct {
+	mu        sync.Mutex
+	providers map[string]Provider // replica.name -> Provider
+	groups    map[string]*group   // LogicalName -> group
+	fallback  map[string]string   // LogicalName -> Fallback LogicalName
+}
+
+// NewRouter 根据部署配置列表构建 Router，为每个部署创建对应厂商的 Provider 实例
+func NewRouter(deployments []DeploymentConfig) (*Router, error) {
+	r := &Router{
+		providers: make(map[string]Provider),
+		groups:    make(map[string]*group),
+		fallback:  make(map[string]string),
+	}
+
+	for i, d := range deployments {
+		if d.LogicalName == "" {
+			return nil, fmt.Errorf("deployment[%d] missing logical name", i)
+		}
+
+		name := fmt.Sprintf("%s#%d", d.LogicalName, i)
+		provider, err := newProvider(name, d)
+		if err != nil {
+			return nil, fmt.Errorf("build provider for %s failed: %w", name, err)
+		}
+		if d.CacheStore != nil {
+			provider = r.wrapWithCache(name, provider, d)
+		}
+		r.providers[name] = provider
+
+		weight := d.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+
+		rep := &replica{
+			config:  d,
+			name:    name,
+			weight:  weight,
+			breaker: newCircuitBreaker(d.FailureThreshold, d.Cooldown),
+		}
+
+		g, ok := r.groups[d.LogicalName]
+		if !ok {
+			g = &group{}
+			r.groups[d.LogicalName] = g
+		}
+		g.replicas = append(g.replicas, rep)
+
+		if d.Fallback != "" {
+			r.fallback[d.LogicalName] = d.Fallback
+		}
+	}
+
+	// 按权重展开轮询序列，例如权重 [3,1] 展开为 [0,0,0,1]
+	for _, g := range r.groups {
+		for idx, rep := range g.replicas {
+			for w := 0; w < rep.weight; w++ {
+				g.expanded = append(g.expanded, idx)
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// newProvider 根据部署配置构建具体厂商的 Provider
+func newProvider(name string, d DeploymentConfig) (Provider, error) {
+	switch d.ProviderType {
+	case ProviderAzureOpenAI:
+		return NewAzureOpenAIProvider(name, LLMProxyConfig{
+			Endpoint: d.Endpoint,
+			APIKey:   d.APIKey,
+			Timeout:  d.Timeout,
+			Model:    d.Model,
+			Metrics:  d.Metrics,
+			Budget:   d.Budget,
+		}, d.LogicalName, d.APIVersion, d.Model), nil
+	case ProviderAnthropic:
+		return NewAnthropicProvider(name, d.Endpoint, d.APIKey, d.Model, d.Timeout), nil
+	case ProviderZhipuAI:
+		return NewZhipuAIProvider(name, d.Endpoint, d.APIKey, d.Model, d.Timeout)
+	case ProviderOllama:
+		return NewOllamaProvider(name, d.Endpoint, d.Model, d.Timeout), nil
+	case ProviderOpenAI, ProviderDeepSeek, ProviderOpenAICompatible, "":
+		return NewOpenAICompatibleProvider(name, d.Endpoint, d.APIKey, d.Model, d.Timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown provider type: %s", d.ProviderType)
+	}
+}
+
+// pickReplica 在 logicalName 对应的分组中按加权轮询选出下一个健康（熔断器未打开）的副本
+func (r *Router) pickReplica(logicalName string) (*replica, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	g, ok := r.groups[logicalName]
+	if !ok || len(g.expanded) == 0 {
+		return nil, false
+	}
+
+	// 最多尝试一整轮，跳过熔断打开的副本
+	for i := 0; i < len(g.expanded); i++ {
+		idx := g.expanded[g.cursor]
+		g.cursor = (g.cursor + 1) % len(g.expanded)
+
+		rep := g.replicas[idx]
+		if rep.breaker.allow() {
+			return rep, true
+		}
+	}
+
+	return nil, false
+}
+
+// resolve 解析出 logicalName 最终可用的副本，必要时沿 Fallback 链路跳转
+func (r *Router) resolve(logicalName string) (*replica, error) {
+	seen := make(map[string]bool)
+	name := logicalName
+
+	for name != "" && !seen[name] {
+		seen[name] = true
+		if rep, ok := r.pickReplica(name); ok {
+			return rep, nil
+		}
+
+		r.mu.Lock()
+		next := r.fallback[name]
+		r.mu.Unlock()
+		if next != "" {
+			logger.Warnw("deployment unavailable, failing over", "deployment_id", name, "fallback_to", next)
+		}
+		name = next
+	}
+
+	return nil, fmt.Errorf("no healthy replica available for deployment %s", logicalName)
+}
+
+// isRetryable 判断一个错误是否应该触发熔断计数和副本间转移（5xx、超时、限流）
+// ctx 被调用方主动取消/超时（Canceled/DeadlineExceeded）不算重试：常见于客户端中途断开 SSE 连接，
+// 这既不是上游故障，也不该连带把健康的副本计入失败、甚至打开熔断器，参见 retry.go 的 isRetryableErr
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "timeout") {
+		return true
+	}
+	if strings.Contains(msg, fmt.Sprintf("status code: %d", http.StatusTooManyRequests)) {
+		return true
+	}
+	for code := 500; code < 600; code++ {
+		if strings.Contains(msg, fmt.Sprintf("status code: %d", code)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ChatCompletion 路由一次非流式对话请求，对可重试错误做副本/Fallback 转移
+func (r *Router) ChatCompletion(ctx context.Context, logicalName string, req *ChatCompletionHTTPRequest) (*ChatCompletionHTTPResponse, error) {
+	rep, err := r.resolve(logicalName)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := r.providers[rep.name]
+	resp, err := provider.ChatCompletion(ctx, req)
+	if err != nil {
+		rep.breaker.recordFailure()
+		if isRetryable(err) {
+			logger.Warnw("provider call failed, retrying with next replica", "deployment_id", logicalName, "provider", rep.name, "error", err)
+			return r.ChatCompletion(ctx, logicalName, req)
+		}
+		return nil, err
+	}
+
+	rep.breaker.recordSuccess()
+	return resp, nil
+}
+
+// ChatCompletionStream 路由一次流式对话请求；流式场景下一旦开始向客户端发送 chunk 就不再做透明转移，避免半截响应
+func (r *Router) ChatCompletionStream(ctx context.Context, logicalName string, req *ChatCompletionHTTPRequest, handler StreamChunkHandler) error {
+	rep, err := r.resolve(logicalName)
+	if err != nil {
+		return err
+	}
+
+	provider := r.providers[rep.name]
+	sent := false
+	trackingHandler := func(chunk *ChatCompletionChunkHTTP) error {
+		sent = true
+		return handler(chunk)
+	}
+	if err := provider.ChatCompletionStream(ctx, req, trackingHandler); err != nil {
+		rep.breaker.recordFailure()
+		if !sent && isRetryable(err) {
+			logger.Warnw("provider stream failed before first chunk, retrying with next replica", "deployment_id", logicalName, "provider", rep.name, "error", err)
+			return r.ChatCompletionStream(ctx, logicalName, req, handler)
+		}
+		return err
+	}
+
+	rep.breaker.recordSuccess()
+	return nil
+}
+
+// Embedding 路由一次 Embedding 请求
+func (r *Router) Embedding(ctx context.Context, logicalName string, input []string) (*EmbeddingHTTPResponse, error) {
+	rep, err := r.resolve(logicalName)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := r.providers[rep.name]
+	resp, err := provider.Embedding(ctx, input)
+	if err != nil {
+		rep.breaker.recordFailure()
+		if isRetryable(err) {
+			return r.Embedding(ctx, logicalName, input)
+		}
+		return nil, err
+	}
+
+	rep.breaker.recordSuccess()
+	return resp, nil
+}
+
+// Close 关闭所有底层 Provider 持有的连接
+func (r *Router) Close() error {
+	for name, provider := range r.providers {
+		if closer, ok := provider.(interface{ Close() error }); ok {
+			if err := closer.Close(); err != nil {
+				logger.Warn("close provider %s failed: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadDeploymentsFromConfig 从通用配置 map 中解析 llm.deployments 列表
+// YAML 形如：
+//
+//	llm:
+//	  deployments:
+//	    - logical_name: gpt4o
+//	      provider_type: azure_openai
+//	      endpoint: https://xxx
+//	      api_key: xxx
+//	      api_version: 2024-06-01
+//	      model: gpt-4o
+//	      weight: 3
+//	      fallback: gpt4o-backup
+//	      cache_enabled: true
+//	      cache_embedding_deployment: text-embedding-3-small
+//	      cache_similarity_threshold_permille: 950
+//	      cache_ttl_seconds: 3600
+func LoadDeploymentsFromConfig(cfg map[string]interface{}) ([]DeploymentConfig, error) {
+	raw := config.GetRaw(cfg, "llm.deployments")
+	if raw == nil {
+		return nil, nil
+	}
+
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("llm.deployments must be a list")
+	}
+
+	deployments := make([]DeploymentConfig, 0, len(list))
+	for i, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("llm.deployments[%d] must be an object", i)
+		}
+
+		d := DeploymentConfig{
+			LogicalName:  stringField(m, "logical_name"),
+			ProviderType: ProviderType(stringField(m, "provider_type")),
+			Endpoint:     stringField(m, "endpoint"),
+			APIKey:       stringField(m, "api_key"),
+			APIVersion:   stringField(m, "api_version"),
+			Model:        stringField(m, "model"),
+			Fallback:     stringField(m, "fallback"),
+			Weight:       intField(m, "weight"),
+
+			CacheEnabled:         boolField(m, "cache_enabled"),
+			CacheEmbedDeployment: stringField(m, "cache_embedding_deployment"),
+			CacheThreshold:       float32(intField(m, "cache_similarity_threshold_permille")) / 1000,
+			CacheTTL:             time.Duration(intField(m, "cache_ttl_seconds")) * time.Second,
+		}
+		deployments = append(deployments, d)
+	}
+
+	return deployments, nil
+}
+
+func stringField(m map[string]interface{}, key string) string {
+	if v, ok := m[key]; ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+func boolField(m map[string]interface{}, key string) bool {
+	b, _ := m[key].(bool)
+	return b
+}
+
+func intField(m map[string]interface{}, key string) int {
+	switch v := m[key].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
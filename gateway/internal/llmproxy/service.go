@@ -4,9 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
+	"time"
+
+	"bot_agent/gateway/internal/cache"
 	"bot_agent/gateway/internal/logger"
+	"bot_agent/gateway/internal/observability"
 	"bot_agent/gateway/internal/pb"
+	"bot_agent/gateway/internal/quota"
 
 	"google.golang.org/grpc"
 )
@@ -15,6 +21,13 @@ import (
 type LLMProxyService struct {
 	pb.UnimplementedLLMProxyServiceServer
 	client *LLMClient
+	// router 非空时优先生效：按请求的 deployment_id 做多厂商路由、加权轮询和故障转移
+	// 为空时退化为单一 client 直连模式，保持旧配置可用
+	router *Router
+	// quotaMgr 非空时对每次调用做 RPM/TPM/月度预算校验，为空表示不限流
+	quotaMgr *quota.Manager
+	// semanticCache 非空时按 req.CachePolicy 对 ChatCompletion/ChatCompletionStream 做语义缓存读写
+	semanticCache *cache.Cache
 }
 
 // NewLLMProxyService 创建 LLM 代理服务
@@ -29,77 +42,253 @@ func (s *LLMProxyService) SetClient(client *LLMClient) {
 	s.client = client
 }
 
+// NewLLMProxyServiceWithRouter 创建仅使用多厂商路由（不配置默认单一部署）的 LLM 代理服务
+func NewLLMProxyServiceWithRouter(router *Router) *LLMProxyService {
+	return &LLMProxyService{router: router}
+}
+
+// SetRouter 设置多厂商路由器；设置后 ChatCompletion/ChatCompletionStream/GetEmbedding 优先走路由
+func (s *LLMProxyService) SetRouter(router *Router) {
+	s.router = router
+}
+
 // ChatCompletion 非流式对话
 func (s *LLMProxyService) ChatCompletion(ctx context.Context, req *pb.ChatCompletionRequest) (*pb.ChatCompletionResponse, error) {
-	logger.Info("ChatCompletion request: deployment_id=%s, messages=%d", req.DeploymentId, len(req.Messages))
+	ctx, span := observability.StartSpan(ctx, "LLMProxyService.ChatCompletion")
+	defer span.End()
 
-	if s.client == nil {
+	log := logger.WithContext(ctx).With(logger.String("deployment_id", req.DeploymentId), logger.String("grpc_method", "ChatCompletion"))
+	log.Infow("chat completion request", "messages", len(req.Messages))
+
+	if s.router == nil && s.client == nil {
 		return nil, fmt.Errorf("LLM client not initialized")
 	}
 
 	// 转换 gRPC 请求为 HTTP 请求
 	httpReq := s.convertToHTTPRequest(req)
 
-	// 调用 LLM API
-	httpResp, err := s.client.ChatCompletion(ctx, req.DeploymentId, req.ApiVersion, httpReq)
+	// 语义缓存：命中则直接返回缓存的响应，完全跳过上游调用和配额预检
+	cachePolicy := cache.ParsePolicy(req.CachePolicy)
+	var cacheText, cacheToolsHash string
+	if s.semanticCache != nil && cachePolicy != cache.PolicyBypass {
+		cacheText = promptTextForCache(httpReq)
+		cacheToolsHash = cache.ToolsHash(httpReq.Tools)
+		if cached, ok := s.semanticCache.Lookup(ctx, cacheText, req.DeploymentId, cacheToolsHash); ok {
+			var cachedResp ChatCompletionHTTPResponse
+			if err := json.Unmarshal([]byte(cached), &cachedResp); err == nil {
+				resp := s.convertToGRPCResponse(&cachedResp)
+				log.Infow("chat completion cache hit", "id", resp.Id)
+				return resp, nil
+			}
+			log.Warnw("chat completion cache hit but payload decode failed")
+		}
+	}
+
+	// 配额预检：按 API Key/租户做 RPM、TPM 预扣，预扣失败直接拒绝，不占用上游调用
+	quotaRes, err := s.reserveQuota(ctx, req.DeploymentId, estimateRequestTokens(httpReq))
 	if err != nil {
-		logger.Error("ChatCompletion failed: %v", err)
+		log.Warnw("chat completion rejected by quota", "error", err)
+		return nil, err
+	}
+
+	// 优先走多厂商路由；未配置路由时退化为直连单一部署
+	upstreamStart := time.Now()
+	var httpResp *ChatCompletionHTTPResponse
+	if s.router != nil {
+		httpResp, err = s.router.ChatCompletion(ctx, req.DeploymentId, httpReq)
+	} else {
+		httpResp, err = s.client.ChatCompletion(ctx, req.DeploymentId, req.ApiVersion, httpReq)
+	}
+	observability.RecordUpstreamLatencySeconds(req.DeploymentId, "total", time.Since(upstreamStart).Seconds())
+	if err != nil {
+		s.rollbackQuota(quotaRes)
+		log.Errorw("chat completion failed", "error", err)
 		return nil, fmt.Errorf("chat completion failed: %w", err)
 	}
+	s.commitQuota(quotaRes, req.DeploymentId, httpResp.Usage.PromptTokens, httpResp.Usage.CompletionTokens)
+	observability.RecordTokens(req.DeploymentId, httpResp.Usage.PromptTokens, httpResp.Usage.CompletionTokens)
+
+	// 未命中缓存时异步写入：read_only 策略只读不写
+	if s.semanticCache != nil && cachePolicy == cache.PolicyReadWrite {
+		if data, err := json.Marshal(httpResp); err == nil {
+			s.semanticCache.Store(cacheText, req.DeploymentId, cacheToolsHash, string(data))
+		}
+	}
 
 	// 转换 HTTP 响应为 gRPC 响应
 	resp := s.convertToGRPCResponse(httpResp)
 
-	logger.Info("ChatCompletion success: id=%s, choices=%d", resp.Id, len(resp.Choices))
+	log.Infow("chat completion success", "id", resp.Id, "choices", len(resp.Choices))
 	return resp, nil
 }
 
-// ChatCompletionStream 流式对话
+// ChatCompletionStream 流式对话（gRPC 入口）
 func (s *LLMProxyService) ChatCompletionStream(req *pb.ChatCompletionRequest, stream grpc.ServerStreamingServer[pb.ChatCompletionChunk]) error {
-	logger.Info("ChatCompletionStream request: deployment_id=%s, messages=%d", req.DeploymentId, len(req.Messages))
+	return s.StreamChatCompletion(stream.Context(), req, stream.Send)
+}
+
+// StreamChatCompletion 流式对话的核心实现，不绑定具体传输协议
+// gRPC 入口（ChatCompletionStream）和 HTTP/SSE 网关都通过传入各自的 send 函数复用这里的缓存、配额和路由逻辑
+func (s *LLMProxyService) StreamChatCompletion(ctx context.Context, req *pb.ChatCompletionRequest, send func(*pb.ChatCompletionChunk) error) error {
+	ctx, span := observability.StartSpan(ctx, "LLMProxyService.ChatCompletionStream")
+	defer span.End()
 
-	if s.client == nil {
+	log := logger.WithContext(ctx).With(logger.String("deployment_id", req.DeploymentId), logger.String("grpc_method", "ChatCompletionStream"))
+	log.Infow("chat completion stream request", "messages", len(req.Messages))
+
+	if s.router == nil && s.client == nil {
 		return fmt.Errorf("LLM client not initialized")
 	}
 
 	// 转换 gRPC 请求为 HTTP 请求
 	httpReq := s.convertToHTTPRequest(req)
 
-	ctx := stream.Context()
-
-	// 调用流式 API
-	err := s.client.ChatCompletionStream(ctx, req.DeploymentId, req.ApiVersion, httpReq, func(chunk *ChatCompletionChunkHTTP) error {
+	upstreamStart := time.Now()
+	var firstChunkAt time.Time
+	var lastUsage UsageHTTP
+	sendChunk := func(chunk *ChatCompletionChunkHTTP) error {
+		if firstChunkAt.IsZero() {
+			firstChunkAt = time.Now()
+			observability.RecordUpstreamLatencySeconds(req.DeploymentId, "ttft", firstChunkAt.Sub(upstreamStart).Seconds())
+		}
+		if chunk.Usage != nil {
+			lastUsage = *chunk.Usage
+		}
 		// 转换为 gRPC chunk 并发送
 		grpcChunk := s.convertToGRPCChunk(chunk)
-		if err := stream.Send(grpcChunk); err != nil {
+		if err := send(grpcChunk); err != nil {
 			return fmt.Errorf("send chunk failed: %w", err)
 		}
 		return nil
-	})
+	}
+
+	// 语义缓存：命中则直接回放缓存响应，不走配额预检和上游调用
+	cachePolicy := cache.ParsePolicy(req.CachePolicy)
+	var cacheText, cacheToolsHash string
+	if s.semanticCache != nil && cachePolicy != cache.PolicyBypass {
+		cacheText = promptTextForCache(httpReq)
+		cacheToolsHash = cache.ToolsHash(httpReq.Tools)
+		if cached, ok := s.semanticCache.Lookup(ctx, cacheText, req.DeploymentId, cacheToolsHash); ok {
+			var cachedResp ChatCompletionHTTPResponse
+			if err := json.Unmarshal([]byte(cached), &cachedResp); err == nil {
+				log.Infow("chat completion stream cache hit")
+				return replayCachedStream(&cachedResp, sendChunk)
+			}
+			log.Warnw("chat completion stream cache hit but payload decode failed")
+		}
+	}
+
+	// 配额预检：流式调用同样按预估 token 数预扣 TPM，实际用量取最后一个带 usage 字段的 chunk
+	quotaRes, err := s.reserveQuota(ctx, req.DeploymentId, estimateRequestTokens(httpReq))
+	if err != nil {
+		log.Warnw("chat completion stream rejected by quota", "error", err)
+		return err
+	}
+
+	// 未命中缓存时，累积完整回复内容，调用成功后异步写入缓存
+	var streamContent strings.Builder
+	var streamRole, streamFinishReason string
+	if s.semanticCache != nil && cachePolicy == cache.PolicyReadWrite {
+		wrapped := sendChunk
+		sendChunk = func(chunk *ChatCompletionChunkHTTP) error {
+			for _, choice := range chunk.Choices {
+				if choice.Delta.Role != "" {
+					streamRole = choice.Delta.Role
+				}
+				streamContent.WriteString(choice.Delta.Content)
+				if choice.FinishReason != "" {
+					streamFinishReason = choice.FinishReason
+				}
+			}
+			return wrapped(chunk)
+		}
+	}
+
+	// 调用流式 API，优先走多厂商路由
+	if s.router != nil {
+		err = s.router.ChatCompletionStream(ctx, req.DeploymentId, httpReq, sendChunk)
+	} else {
+		err = s.client.ChatCompletionStream(ctx, req.DeploymentId, req.ApiVersion, httpReq, sendChunk)
+	}
+	observability.RecordUpstreamLatencySeconds(req.DeploymentId, "total", time.Since(upstreamStart).Seconds())
+
+	// 客户端提前取消或调用失败时，用实际收到的 usage 核销，未收到任何 usage 则整笔回滚预扣
+	if err != nil || ctx.Err() != nil {
+		if lastUsage.TotalTokens > 0 {
+			s.commitQuota(quotaRes, req.DeploymentId, lastUsage.PromptTokens, lastUsage.CompletionTokens)
+		} else {
+			s.rollbackQuota(quotaRes)
+		}
+	} else {
+		s.commitQuota(quotaRes, req.DeploymentId, lastUsage.PromptTokens, lastUsage.CompletionTokens)
+	}
+	observability.RecordTokens(req.DeploymentId, lastUsage.PromptTokens, lastUsage.CompletionTokens)
 
 	if err != nil {
-		logger.Error("ChatCompletionStream failed: %v", err)
+		log.Errorw("chat completion stream failed", "error", err)
 		return fmt.Errorf("chat completion stream failed: %w", err)
 	}
 
-	logger.Info("ChatCompletionStream completed")
+	// 流式调用正常结束（非客户端取消）且开启了读写缓存时，异步写入本轮累积的完整回复
+	if s.semanticCache != nil && cachePolicy == cache.PolicyReadWrite && ctx.Err() == nil {
+		cachedResp := &ChatCompletionHTTPResponse{
+			Model: req.DeploymentId,
+			Usage: lastUsage,
+			Choices: []ChoiceHTTP{
+				{
+					Message:      ChatMessageHTTP{Role: streamRole, Content: streamContent.String()},
+					FinishReason: streamFinishReason,
+				},
+			},
+		}
+		if data, err := json.Marshal(cachedResp); err == nil {
+			s.semanticCache.Store(cacheText, req.DeploymentId, cacheToolsHash, string(data))
+		}
+	}
+
+	log.Infow("chat completion stream completed")
 	return nil
 }
 
 // GetEmbedding 获取 Embedding 向量
 func (s *LLMProxyService) GetEmbedding(ctx context.Context, req *pb.EmbeddingRequest) (*pb.EmbeddingResponse, error) {
-	logger.Info("GetEmbedding request: deployment_id=%s, input_count=%d", req.DeploymentId, len(req.Input))
+	ctx, span := observability.StartSpan(ctx, "LLMProxyService.GetEmbedding")
+	defer span.End()
+
+	log := logger.WithContext(ctx).With(logger.String("deployment_id", req.DeploymentId), logger.String("grpc_method", "GetEmbedding"))
+	log.Infow("get embedding request", "input_count", len(req.Input))
 
-	if s.client == nil {
+	if s.router == nil && s.client == nil {
 		return nil, fmt.Errorf("LLM client not initialized")
 	}
 
-	// 调用 Embedding API
-	httpResp, err := s.client.GetEmbedding(ctx, req.DeploymentId, req.ApiVersion, req.Input)
+	// 配额预检：Embedding 按输入文本总长度粗略估算 token 数
+	estimatedTokens := int32(0)
+	for _, text := range req.Input {
+		estimatedTokens += int32(len(text)/4) + 1
+	}
+	quotaRes, err := s.reserveQuota(ctx, req.DeploymentId, estimatedTokens)
+	if err != nil {
+		log.Warnw("get embedding rejected by quota", "error", err)
+		return nil, err
+	}
+
+	// 调用 Embedding API，优先走多厂商路由
+	upstreamStart := time.Now()
+	var httpResp *EmbeddingHTTPResponse
+	if s.router != nil {
+		httpResp, err = s.router.Embedding(ctx, req.DeploymentId, req.Input)
+	} else {
+		httpResp, err = s.client.GetEmbedding(ctx, req.DeploymentId, req.ApiVersion, req.Input)
+	}
+	observability.RecordUpstreamLatencySeconds(req.DeploymentId, "total", time.Since(upstreamStart).Seconds())
 	if err != nil {
-		logger.Error("GetEmbedding failed: %v", err)
+		s.rollbackQuota(quotaRes)
+		log.Errorw("get embedding failed", "error", err)
 		return nil, fmt.Errorf("get embedding failed: %w", err)
 	}
+	s.commitQuota(quotaRes, req.DeploymentId, httpResp.Usage.PromptTokens, 0)
+	observability.RecordTokens(req.DeploymentId, httpResp.Usage.PromptTokens, 0)
 
 	// 转换响应
 	resp := &pb.EmbeddingResponse{
@@ -121,12 +310,17 @@ func (s *LLMProxyService) GetEmbedding(ctx context.Context, req *pb.EmbeddingReq
 		}
 	}
 
-	logger.Info("GetEmbedding success: data_count=%d", len(resp.Data))
+	log.Infow("get embedding success", "data_count", len(resp.Data))
 	return resp, nil
 }
 
 // Close 关闭服务
 func (s *LLMProxyService) Close() error {
+	if s.router != nil {
+		if err := s.router.Close(); err != nil {
+			return err
+		}
+	}
 	if s.client != nil {
 		return s.client.Close()
 	}
@@ -372,3 +566,227 @@ func (s *LLMProxyService) convertToGRPCChunk(httpChunk *ChatCompletionChunkHTTP)
 
 	return chunk
 }
+
+// ==================== HTTP/SSE 网关转换 ====================
+// 以下函数是上面 gRPC<->内部 HTTP 结构转换的逆方向，供 HTTP/SSE 网关（internal/httpgateway）
+// 把浏览器/OpenAI SDK 发来的 OpenAI 兼容 JSON 请求转换为 pb 请求，复用 StreamChatCompletion 里
+// 已有的缓存、配额、路由逻辑，再把流式 chunk 转换回去通过 SSE 下发
+
+// RequestFromHTTP 把 OpenAI 兼容的 HTTP 请求体转换为 gRPC 请求
+func RequestFromHTTP(httpReq *ChatCompletionHTTPRequest, deploymentID string) *pb.ChatCompletionRequest {
+	req := &pb.ChatCompletionRequest{
+		DeploymentId: deploymentID,
+		Messages:     messagesFromHTTP(httpReq.Messages),
+		Stop:         httpReq.Stop,
+		User:         httpReq.User,
+	}
+
+	if httpReq.Temperature != nil {
+		req.Temperature = *httpReq.Temperature
+	}
+	if httpReq.MaxCompletionTokens != nil {
+		req.MaxTokens = *httpReq.MaxCompletionTokens
+	}
+	if httpReq.TopP != nil {
+		req.TopP = *httpReq.TopP
+	}
+	if httpReq.FrequencyPenalty != nil {
+		req.FrequencyPenalty = *httpReq.FrequencyPenalty
+	}
+	if httpReq.PresencePenalty != nil {
+		req.PresencePenalty = *httpReq.PresencePenalty
+	}
+	if httpReq.N != nil {
+		req.N = *httpReq.N
+	}
+	if httpReq.Seed != nil {
+		req.Seed = *httpReq.Seed
+	}
+	if httpReq.ResponseFormat != nil {
+		req.ResponseFormat = httpReq.ResponseFormat.Type
+	}
+	if len(httpReq.Tools) > 0 {
+		req.Tools = toolsFromHTTP(httpReq.Tools)
+	}
+	if toolChoice, ok := httpReq.ToolChoice.(string); ok {
+		req.ToolChoice = toolChoice
+	}
+
+	return req
+}
+
+// messagesFromHTTP 把 HTTP 消息列表转换为 gRPC 消息列表
+func messagesFromHTTP(messages []ChatMessageHTTP) []*pb.ChatMessage {
+	result := make([]*pb.ChatMessage, len(messages))
+	for i, msg := range messages {
+		pbMsg := &pb.ChatMessage{
+			Role:       msg.Role,
+			Name:       msg.Name,
+			ToolCallId: msg.ToolCallID,
+		}
+
+		switch content := msg.Content.(type) {
+		case string:
+			pbMsg.ContentType = &pb.ChatMessage_Content{Content: content}
+		case []interface{}:
+			parts := &pb.ContentList{Parts: make([]*pb.ContentPart, len(content))}
+			for j, part := range content {
+				partMap, ok := part.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				contentPart := &pb.ContentPart{}
+				if t, ok := partMap["type"].(string); ok {
+					contentPart.Type = t
+				}
+				if text, ok := partMap["text"].(string); ok {
+					contentPart.Text = text
+				}
+				if imageURL, ok := partMap["image_url"].(map[string]interface{}); ok {
+					img := &pb.ImageURL{}
+					if u, ok := imageURL["url"].(string); ok {
+						img.Url = u
+					}
+					if d, ok := imageURL["detail"].(string); ok {
+						img.Detail = d
+					}
+					contentPart.ImageUrl = img
+				}
+				parts.Parts[j] = contentPart
+			}
+			pbMsg.ContentType = &pb.ChatMessage_ContentParts{ContentParts: parts}
+		}
+
+		if len(msg.ToolCalls) > 0 {
+			pbMsg.ToolCalls = make([]*pb.ToolCall, len(msg.ToolCalls))
+			for j, tc := range msg.ToolCalls {
+				pbMsg.ToolCalls[j] = &pb.ToolCall{
+					Id:   tc.ID,
+					Type: tc.Type,
+					Function: &pb.FunctionCall{
+						Name:      tc.Function.Name,
+						Arguments: tc.Function.Arguments,
+					},
+				}
+			}
+		}
+
+		result[i] = pbMsg
+	}
+	return result
+}
+
+// toolsFromHTTP 把 HTTP 工具定义转换为 gRPC 工具定义
+func toolsFromHTTP(tools []ToolHTTP) []*pb.Tool {
+	result := make([]*pb.Tool, len(tools))
+	for i, tool := range tools {
+		pbTool := &pb.Tool{
+			Type: tool.Type,
+			Function: &pb.FunctionDef{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+			},
+		}
+		if tool.Function.Parameters != nil {
+			if raw, err := json.Marshal(tool.Function.Parameters); err == nil {
+				pbTool.Function.Parameters = string(raw)
+			}
+		}
+		result[i] = pbTool
+	}
+	return result
+}
+
+// ChunkToHTTP 把 gRPC 流式块转换回 OpenAI 兼容的 HTTP chunk，供 HTTP/SSE 网关透传给浏览器客户端
+func ChunkToHTTP(chunk *pb.ChatCompletionChunk) *ChatCompletionChunkHTTP {
+	httpChunk := &ChatCompletionChunkHTTP{
+		ID:      chunk.Id,
+		Object:  chunk.Object,
+		Created: chunk.Created,
+		Model:   chunk.Model,
+		Choices: make([]StreamChoiceHTTP, len(chunk.Choices)),
+	}
+
+	if chunk.Usage != nil {
+		httpChunk.Usage = &UsageHTTP{
+			PromptTokens:     chunk.Usage.PromptTokens,
+			CompletionTokens: chunk.Usage.CompletionTokens,
+			TotalTokens:      chunk.Usage.TotalTokens,
+		}
+	}
+
+	for i, choice := range chunk.Choices {
+		httpChunk.Choices[i] = StreamChoiceHTTP{
+			Index:        choice.Index,
+			Delta:        ChatMessageDeltaHTTP{Role: choice.Delta.Role, Content: choice.Delta.Content},
+			FinishReason: choice.FinishReason,
+		}
+	}
+
+	return httpChunk
+}
+
+// ResponseToHTTP 把 gRPC 响应转换回 OpenAI 兼容的 HTTP 响应，供 HTTP/SSE 网关的非流式请求序列化返回
+func ResponseToHTTP(resp *pb.ChatCompletionResponse) *ChatCompletionHTTPResponse {
+	httpResp := &ChatCompletionHTTPResponse{
+		ID:      resp.Id,
+		Object:  resp.Object,
+		Created: resp.Created,
+		Model:   resp.Model,
+		Choices: make([]ChoiceHTTP, len(resp.Choices)),
+	}
+
+	if resp.Usage != nil {
+		httpResp.Usage = UsageHTTP{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		}
+	}
+
+	for i, choice := range resp.Choices {
+		httpResp.Choices[i] = ChoiceHTTP{
+			Index:        choice.Index,
+			Message:      messageToHTTP(choice.Message),
+			FinishReason: choice.FinishReason,
+		}
+	}
+
+	return httpResp
+}
+
+// messageToHTTP 把 gRPC 消息转换回 HTTP 消息
+func messageToHTTP(msg *pb.ChatMessage) ChatMessageHTTP {
+	httpMsg := ChatMessageHTTP{
+		Role:       msg.Role,
+		Name:       msg.Name,
+		ToolCallID: msg.ToolCallId,
+	}
+
+	switch content := msg.ContentType.(type) {
+	case *pb.ChatMessage_Content:
+		httpMsg.Content = content.Content
+	case *pb.ChatMessage_ContentParts:
+		parts := make([]ContentPartHTTP, len(content.ContentParts.Parts))
+		for i, part := range content.ContentParts.Parts {
+			parts[i] = ContentPartHTTP{Type: part.Type, Text: part.Text}
+			if part.ImageUrl != nil {
+				parts[i].ImageURL = &ImageURLHTTP{URL: part.ImageUrl.Url, Detail: part.ImageUrl.Detail}
+			}
+		}
+		httpMsg.Content = parts
+	}
+
+	if len(msg.ToolCalls) > 0 {
+		httpMsg.ToolCalls = make([]ToolCallHTTP, len(msg.ToolCalls))
+		for i, tc := range msg.ToolCalls {
+			httpMsg.ToolCalls[i] = ToolCallHTTP{
+				ID:       tc.Id,
+				Type:     tc.Type,
+				Function: FunctionCallHTTP{Name: tc.Function.Name, Arguments: tc.Function.Arguments},
+			}
+		}
+	}
+
+	return httpMsg
+}
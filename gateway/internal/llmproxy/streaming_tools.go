@@ -0,0 +1,204 @@
+package llmproxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"bot_agent/gateway/internal/logger"
+)
+
+// StreamHandler 是比 StreamChunkHandler 更高层的流式回调：调用方不用自己处理 SSE chunk 的原始结构，
+// 也不用自己拼接分片的 tool_calls，三类事件分开回调
+type StreamHandler interface {
+	// OnContent 收到一段增量文本内容
+	OnContent(content string) error
+	// OnToolCall 在某个 tool call 的 id/name/arguments 分片拼接完整（arguments 能解析为合法 JSON）后触发，
+	// 可能在流结束前多次调用，每个 index 只触发一次
+	OnToolCall(call ToolCallHTTP) error
+	// OnFinish 流正常结束时触发一次，reason 是最后一个 chunk 的 finish_reason，usage 可能为 nil
+	OnFinish(reason string, usage *UsageHTTP) error
+}
+
+// toolCallAssembler 把跨多个 chunk 到达的 ToolCallDeltaHTTP 按 index 拼接成完整的 ToolCallHTTP
+type toolCallAssembler struct {
+	byIndex    map[int]*ToolCallHTTP
+	order      []int
+	dispatched map[int]bool
+}
+
+func newToolCallAssembler() *toolCallAssembler {
+	return &toolCallAssembler{
+		byIndex:    make(map[int]*ToolCallHTTP),
+		dispatched: make(map[int]bool),
+	}
+}
+
+// merge 把一批分片并入对应 index 的 ToolCallHTTP，返回本次合并后新晋"完整"的 tool call
+// （id/name 已知且 arguments 是合法 JSON，且此前没有被判定为完整过）
+func (a *toolCallAssembler) merge(deltas []ToolCallDeltaHTTP) []ToolCallHTTP {
+	var ready []ToolCallHTTP
+
+	for _, d := range deltas {
+		call, ok := a.byIndex[d.Index]
+		if !ok {
+			call = &ToolCallHTTP{Type: "function"}
+			a.byIndex[d.Index] = call
+			a.order = append(a.order, d.Index)
+		}
+		if d.ID != "" {
+			call.ID = d.ID
+		}
+		if d.Type != "" {
+			call.Type = d.Type
+		}
+		call.Function.Name += d.Function.Name
+		call.Function.Arguments += d.Function.Arguments
+
+		if a.dispatched[d.Index] || call.ID == "" || call.Function.Name == "" {
+			continue
+		}
+		if !json.Valid([]byte(call.Function.Arguments)) {
+			continue
+		}
+		a.dispatched[d.Index] = true
+		ready = append(ready, *call)
+	}
+
+	return ready
+}
+
+// remaining 返回流结束时仍未被判定为"完整"的 tool call（通常是模型截断输出导致 arguments 不是合法 JSON），
+// 按首次出现的 index 顺序排列，仅用于日志排查
+func (a *toolCallAssembler) remaining() []ToolCallHTTP {
+	var calls []ToolCallHTTP
+	for _, idx := range a.order {
+		if a.dispatched[idx] {
+			continue
+		}
+		calls = append(calls, *a.byIndex[idx])
+	}
+	return calls
+}
+
+// ChatCompletionStreamWithHandler 在 ChatCompletionStream 之上做一层 tool_calls 分片拼接：
+// 内容增量实时转发给 OnContent，tool call 一旦参数拼出合法 JSON 就立刻回调 OnToolCall，
+// 流结束时回调 OnFinish；对无法拼出合法 JSON 的残缺 tool call 只记日志，不回调 OnToolCall
+func (c *LLMClient) ChatCompletionStreamWithHandler(ctx context.Context, deploymentID, apiVersion string, req *ChatCompletionHTTPRequest, handler StreamHandler) error {
+	assembler := newToolCallAssembler()
+	var finishReason string
+	var usage *UsageHTTP
+
+	err := c.ChatCompletionStream(ctx, deploymentID, apiVersion, req, func(chunk *ChatCompletionChunkHTTP) error {
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content != "" {
+				if err := handler.OnContent(choice.Delta.Content); err != nil {
+					return err
+				}
+			}
+			for _, call := range assembler.merge(choice.Delta.ToolCalls) {
+				if err := handler.OnToolCall(call); err != nil {
+					return err
+				}
+			}
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if leftover := assembler.remaining(); len(leftover) > 0 {
+		logger.Warn("stream ended with %d incomplete tool call(s), arguments did not parse as JSON", len(leftover))
+	}
+
+	return handler.OnFinish(finishReason, usage)
+}
+
+// ToolRegistry 把函数名映射到实际执行逻辑，AutoDispatch 用它来执行模型请求的 tool call，
+// argsJSON 是 ToolCallHTTP.Function.Arguments 原样透传，返回值会作为 tool 消息的 content 回灌给模型
+type ToolRegistry map[string]func(ctx context.Context, argsJSON string) (string, error)
+
+// autoDispatchHandler 把 StreamHandler 的三个回调接到"攒内容 + 攒待执行 tool call"上，
+// 具体的工具执行和续写请求在 AutoDispatch 的主循环里做，这里只负责单轮流式收集
+type autoDispatchHandler struct {
+	content   strings.Builder
+	toolCalls []ToolCallHTTP
+}
+
+func (h *autoDispatchHandler) OnContent(content string) error {
+	h.content.WriteString(content)
+	return nil
+}
+
+func (h *autoDispatchHandler) OnToolCall(call ToolCallHTTP) error {
+	h.toolCalls = append(h.toolCalls, call)
+	return nil
+}
+
+func (h *autoDispatchHandler) OnFinish(reason string, usage *UsageHTTP) error {
+	return nil
+}
+
+// AutoDispatch 把 LLMClient 变成一个简单的 agent 循环：流式请求模型并收集参数已拼完整的 tool call，
+// 本轮流结束后按收集顺序逐个从 registry 查找并执行（流进行中不会执行任何工具），执行结果作为 role=tool
+// 消息追加进对话历史，再发起下一轮流式请求，如此反复直到某一轮模型不再请求任何 tool call 为止，
+// 返回模型最终的文本内容
+func (c *LLMClient) AutoDispatch(ctx context.Context, deploymentID, apiVersion string, req *ChatCompletionHTTPRequest, registry ToolRegistry, maxRounds int) (string, error) {
+	if maxRounds <= 0 {
+		maxRounds = 10
+	}
+
+	messages := append([]ChatMessageHTTP{}, req.Messages...)
+
+	for round := 0; round < maxRounds; round++ {
+		roundReq := *req
+		roundReq.Messages = messages
+
+		h := &autoDispatchHandler{}
+		if err := c.ChatCompletionStreamWithHandler(ctx, deploymentID, apiVersion, &roundReq, h); err != nil {
+			return "", fmt.Errorf("stream round %d failed: %w", round, err)
+		}
+
+		if len(h.toolCalls) == 0 {
+			return h.content.String(), nil
+		}
+
+		messages = append(messages, ChatMessageHTTP{
+			Role:      "assistant",
+			Content:   h.content.String(),
+			ToolCalls: h.toolCalls,
+		})
+
+		for _, call := range h.toolCalls {
+			fn, ok := registry[call.Function.Name]
+			if !ok {
+				messages = append(messages, ChatMessageHTTP{
+					Role:       "tool",
+					ToolCallID: call.ID,
+					Content:    fmt.Sprintf("error: no tool registered for function %q", call.Function.Name),
+				})
+				continue
+			}
+
+			result, err := fn(ctx, call.Function.Arguments)
+			if err != nil {
+				result = fmt.Sprintf("error: %v", err)
+			}
+			messages = append(messages, ChatMessageHTTP{
+				Role:       "tool",
+				ToolCallID: call.ID,
+				Content:    result,
+			})
+		}
+	}
+
+	return "", fmt.Errorf("auto dispatch did not converge after %d rounds", maxRounds)
+}
@@ -0,0 +1,156 @@
+package llmproxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"bot_agent/gateway/internal/logger"
+)
+
+// defaultStructuredContentPath 是解析结构化输出时默认读取的响应字段路径，对应
+// ChatCompletionHTTPResponse.Choices[0].Message.Content
+const defaultStructuredContentPath = "choices.0.message.content"
+
+// StructuredOutputOptions 是 ChatCompletionStructured 的可选配置，零值即为默认行为
+type StructuredOutputOptions struct {
+	// ContentPath 是从响应里取出待校验 JSON 文本的字段路径，形如 "choices.0.message.content"，
+	// 留空则使用 defaultStructuredContentPath
+	ContentPath string
+	// MaxRetries 是校验失败后的自我修复重试次数，<=0 时使用 LLMProxyConfig.MaxRetries
+	MaxRetries int
+	// Target 不为 nil 时，校验通过后额外把 JSON 反序列化进这个指针（通过 encoding/json 的反射），
+	// 调用方可以直接拿到强类型结果而不必自己再 Unmarshal 一次
+	Target interface{}
+}
+
+// StructuredResult 是 ChatCompletionStructured 的返回值：既保留原始响应，又给出已校验通过的 JSON
+type StructuredResult struct {
+	Raw  *ChatCompletionHTTPResponse
+	Data map[string]interface{}
+}
+
+// ChatCompletionStructured 在 ChatCompletion 之上加一层 JSON Schema 校验和自我修复重试：
+// 把 schema 注入 system 提示词、强制 response_format=json_object，解析响应 JSON 并按 schema 校验，
+// 校验失败时把具体的错误信息追加成一轮 user 消息重新请求模型，直到通过或用完重试次数
+func (c *LLMClient) ChatCompletionStructured(ctx context.Context, deploymentID, apiVersion string, req *ChatCompletionHTTPRequest, schema JSONSchema, opts *StructuredOutputOptions) (*StructuredResult, error) {
+	if opts == nil {
+		opts = &StructuredOutputOptions{}
+	}
+	contentPath := opts.ContentPath
+	if contentPath == "" {
+		contentPath = defaultStructuredContentPath
+	}
+	maxRetries := opts.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = c.config.MaxRetries
+	}
+
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("marshal json schema failed: %w", err)
+	}
+
+	// 复制一份消息和 response_format，不改动调用方传入的 req，重试时在这份副本上追加消息
+	messages := append([]ChatMessageHTTP{}, req.Messages...)
+	messages = append([]ChatMessageHTTP{{
+		Role: "system",
+		Content: fmt.Sprintf("You must respond with a single JSON object that strictly conforms to the following JSON Schema. "+
+			"Do not include any text outside the JSON object.\nJSON Schema:\n%s", string(schemaJSON)),
+	}}, messages...)
+
+	attemptReq := *req
+	attemptReq.Messages = messages
+	attemptReq.ResponseFormat = &ResponseFormat{Type: "json_object"}
+
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		resp, err := c.ChatCompletion(ctx, deploymentID, apiVersion, &attemptReq)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := extractContentAtPath(resp, contentPath)
+		if err != nil {
+			lastErr = err
+			attemptReq.Messages = append(attemptReq.Messages, ChatMessageHTTP{
+				Role:    "user",
+				Content: fmt.Sprintf("Your previous response could not be read: %v. Respond again with only the JSON object.", err),
+			})
+			continue
+		}
+
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(content), &data); err != nil {
+			lastErr = fmt.Errorf("response is not valid JSON: %w", err)
+			attemptReq.Messages = append(attemptReq.Messages, ChatMessageHTTP{
+				Role:    "user",
+				Content: fmt.Sprintf("Your previous response was not valid JSON (%v). Respond again with only the JSON object.", err),
+			})
+			continue
+		}
+
+		if validationErrs := validateJSONSchema(schema, data, ""); len(validationErrs) > 0 {
+			lastErr = fmt.Errorf("schema validation failed: %s", strings.Join(validationErrs, "; "))
+			logger.Warnw("structured output failed schema validation, retrying", "attempt", attempt, "errors", validationErrs)
+			attemptReq.Messages = append(attemptReq.Messages, ChatMessageHTTP{
+				Role: "user",
+				Content: fmt.Sprintf("Your previous response did not conform to the JSON Schema. Fix the following issues and "+
+					"respond again with only the corrected JSON object:\n- %s", strings.Join(validationErrs, "\n- ")),
+			})
+			continue
+		}
+
+		if opts.Target != nil {
+			if err := json.Unmarshal([]byte(content), opts.Target); err != nil {
+				return nil, fmt.Errorf("unmarshal structured output into target failed: %w", err)
+			}
+		}
+
+		return &StructuredResult{Raw: resp, Data: data}, nil
+	}
+
+	return nil, fmt.Errorf("structured output still invalid after %d retries: %w", maxRetries, lastErr)
+}
+
+// extractContentAtPath 按 "." 分隔的路径从响应里取出字符串内容，数字段表示数组下标，
+// 比如默认路径 "choices.0.message.content" 对应 resp.Choices[0].Message.Content
+func extractContentAtPath(resp *ChatCompletionHTTPResponse, path string) (string, error) {
+	respJSON, err := json.Marshal(resp)
+	if err != nil {
+		return "", fmt.Errorf("marshal response failed: %w", err)
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(respJSON, &generic); err != nil {
+		return "", fmt.Errorf("unmarshal response failed: %w", err)
+	}
+
+	cur := generic
+	for _, segment := range strings.Split(path, ".") {
+		switch node := cur.(type) {
+		case map[string]interface{}:
+			v, ok := node[segment]
+			if !ok {
+				return "", fmt.Errorf("content path %q: field %q not found", path, segment)
+			}
+			cur = v
+		case []interface{}:
+			idx, err := strconv.Atoi(segment)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return "", fmt.Errorf("content path %q: invalid array index %q", path, segment)
+			}
+			cur = node[idx]
+		default:
+			return "", fmt.Errorf("content path %q: cannot descend into %T at %q", path, cur, segment)
+		}
+	}
+
+	content, ok := cur.(string)
+	if !ok {
+		return "", fmt.Errorf("content path %q: resolved value is not a string (%T)", path, cur)
+	}
+	return content, nil
+}
@@ -0,0 +1,115 @@
+package llmproxy
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ZhipuAIProvider 适配智谱 GLM 的 `/api/paas/v4/chat/completions`，协议本身是 OpenAI 兼容格式，
+// 区别只在鉴权：ApiKey 形如 "id.secret"，需要用 secret 现场签发一个短时效的 JWT 作为 Bearer token，
+// 而不是把 ApiKey 原样透传，所以不能直接复用 OpenAICompatibleProvider
+type ZhipuAIProvider struct {
+	inner     *OpenAICompatibleProvider
+	keyID     string
+	keySecret string
+}
+
+const zhipuDefaultEndpoint = "https://open.bigmodel.cn/api/paas/v4"
+
+// zhipuTokenTTL 是签发的 JWT 有效期，留出余量避免长连接/重试过程中过期
+const zhipuTokenTTL = 5 * time.Minute
+
+// NewZhipuAIProvider 创建智谱 GLM Provider，apiKey 必须是 "id.secret" 格式（控制台下发的原始 API Key）
+func NewZhipuAIProvider(name, endpoint, apiKey, model string, timeout time.Duration) (*ZhipuAIProvider, error) {
+	if endpoint == "" {
+		endpoint = zhipuDefaultEndpoint
+	}
+	id, secret, ok := strings.Cut(apiKey, ".")
+	if !ok {
+		return nil, fmt.Errorf("zhipuai api key must be in \"id.secret\" format")
+	}
+
+	return &ZhipuAIProvider{
+		inner:     NewOpenAICompatibleProvider(name, endpoint, "", model, timeout),
+		keyID:     id,
+		keySecret: secret,
+	}, nil
+}
+
+// signToken 按智谱约定的 HS256 JWT 格式签发 token：header {alg, sign_type}, payload {api_key, exp, timestamp}
+func (p *ZhipuAIProvider) signToken() (string, error) {
+	now := time.Now()
+	header := map[string]string{"alg": "HS256", "sign_type": "SIGN"}
+	payload := map[string]interface{}{
+		"api_key":   p.keyID,
+		"timestamp": now.UnixMilli(),
+		"exp":       now.Add(zhipuTokenTTL).UnixMilli(),
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("marshal jwt header failed: %w", err)
+	}
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("marshal jwt payload failed: %w", err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(payloadJSON)
+
+	mac := hmac.New(sha256.New, []byte(p.keySecret))
+	mac.Write([]byte(signingInput))
+	signature := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	return signingInput + "." + signature, nil
+}
+
+func (p *ZhipuAIProvider) Name() string { return p.inner.Name() }
+
+func (p *ZhipuAIProvider) Supports(model string) bool { return p.inner.Supports(model) }
+
+func (p *ZhipuAIProvider) withToken() (*OpenAICompatibleProvider, error) {
+	token, err := p.signToken()
+	if err != nil {
+		return nil, err
+	}
+	// apiKey 在 OpenAICompatibleProvider 里只用来拼 "Bearer <apiKey>"，这里每次请求前换上最新签发的 JWT
+	scoped := *p.inner
+	scoped.apiKey = token
+	return &scoped, nil
+}
+
+func (p *ZhipuAIProvider) ChatCompletion(ctx context.Context, req *ChatCompletionHTTPRequest) (*ChatCompletionHTTPResponse, error) {
+	scoped, err := p.withToken()
+	if err != nil {
+		return nil, err
+	}
+	return scoped.ChatCompletion(ctx, req)
+}
+
+func (p *ZhipuAIProvider) ChatCompletionStream(ctx context.Context, req *ChatCompletionHTTPRequest, handler StreamChunkHandler) error {
+	scoped, err := p.withToken()
+	if err != nil {
+		return err
+	}
+	return scoped.ChatCompletionStream(ctx, req, handler)
+}
+
+func (p *ZhipuAIProvider) Embedding(ctx context.Context, input []string) (*EmbeddingHTTPResponse, error) {
+	scoped, err := p.withToken()
+	if err != nil {
+		return nil, err
+	}
+	return scoped.Embedding(ctx, input)
+}
+
+// Close 关闭空闲连接
+func (p *ZhipuAIProvider) Close() error {
+	return p.inner.Close()
+}
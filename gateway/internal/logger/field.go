@@ -0,0 +1,59 @@
+package logger
+
+import "fmt"
+
+// Field 表示一个结构化日志字段（key/value）
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// String 构造字符串类型字段
+func String(key, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Int 构造整数类型字段
+func Int(key string, value int) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Int64 构造 int64 类型字段
+func Int64(key string, value int64) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Bool 构造布尔类型字段
+func Bool(key string, value bool) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Err 构造 error 类型字段，固定 key 为 "error"
+func Err(err error) Field {
+	if err == nil {
+		return Field{Key: "error", Value: nil}
+	}
+	return Field{Key: "error", Value: err.Error()}
+}
+
+// Any 构造任意类型字段
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// fieldsFromKV 将 Debugw/Infow 等方法的 k/v 可变参数转换为 Field 列表
+// 要求 kvs 的长度为偶数，且奇数位置为字符串 key；否则丢弃末尾无法配对的值
+func fieldsFromKV(kvs ...interface{}) []Field {
+	if len(kvs) == 0 {
+		return nil
+	}
+	fields := make([]Field, 0, len(kvs)/2)
+	for i := 0; i+1 < len(kvs); i += 2 {
+		key, ok := kvs[i].(string)
+		if !ok {
+			key = fmt.Sprintf("%v", kvs[i])
+		}
+		fields = append(fields, Field{Key: key, Value: kvs[i+1]})
+	}
+	return fields
+}
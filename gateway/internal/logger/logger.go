@@ -1,15 +1,16 @@
 package logger
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	"io"
-	"log"
 	"os"
-	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"gopkg.in/natefinch/lumberjack.v2"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Level 定义日志级别
@@ -32,14 +33,30 @@ var levelNames = map[Level]string{
 	FATAL: "FATAL",
 }
 
+// Format 日志输出格式
+type Format int
+
+const (
+	// FormatText 现有的人类可读文本格式："[时间] [级别] 消息 key=value ..."
+	FormatText Format = iota
+	// FormatJSON 每行一个 JSON 对象，便于日志采集系统解析
+	FormatJSON
+)
+
+// Rotator 由支持手动轮转的 Sink 实现（如 FileSink）
+type Rotator interface {
+	Rotate() error
+}
+
 // Logger 日志器结构体
 type Logger struct {
-	mu           sync.Mutex
-	level        Level
-	logger       *log.Logger
-	lumberjack   *lumberjack.Logger // 使用 lumberjack 进行日志轮转
-	enableFile   bool
-	enableStdio  bool
+	mu      sync.Mutex
+	level   *atomic.Int32 // 级别原子存储，支持从管理接口无锁修改，不影响写日志热路径
+	format  Format
+	fields  []Field // With() 附加的上下文字段，会追加到每条日志
+	sinks   []Sink
+	hooks   []Hook
+	sampler *sampler
 }
 
 // Config 日志配置
@@ -54,6 +71,15 @@ type Config struct {
 	MaxBackups int  // 保留的旧日志文件最大数量，默认 7 个
 	MaxAge     int  // 保留旧日志文件的最大天数，默认 30 天
 	Compress   bool // 是否压缩旧日志文件，默认 true
+
+	Format Format // 输出格式，默认 FormatText
+
+	// Sinks 自定义输出目标列表。非空时完全取代由 EnableFile/EnableStdio 构建的默认 Sink
+	Sinks []Sink
+	// Hooks 写日志前后触发的钩子
+	Hooks []Hook
+	// Sampling 按级别配置每秒最大日志条数，用于在高负载下丢弃多余的 DEBUG/INFO 日志；不配置则不限流
+	Sampling map[Level]int64
 }
 
 // DefaultConfig 返回默认配置
@@ -67,6 +93,7 @@ func DefaultConfig() *Config {
 		MaxBackups:  7,   // 保留 7 个备份
 		MaxAge:      30,  // 保留 30 天
 		Compress:    true,
+		Format:      FormatText,
 	}
 }
 
@@ -101,99 +128,215 @@ func NewLogger(cfg *Config) (*Logger, error) {
 		cfg.MaxAge = 30
 	}
 
+	level := &atomic.Int32{}
+	level.Store(int32(cfg.Level))
+
 	l := &Logger{
-		level:       cfg.Level,
-		enableFile:  cfg.EnableFile,
-		enableStdio: cfg.EnableStdio,
+		level:  level,
+		format: cfg.Format,
+		hooks:  cfg.Hooks,
 	}
 
-	var writers []io.Writer
-
-	// 控制台输出
-	if cfg.EnableStdio {
-		writers = append(writers, os.Stdout)
+	if len(cfg.Sampling) > 0 {
+		l.sampler = newSampler(cfg.Sampling)
 	}
 
-	// 文件输出（使用 lumberjack 进行日志轮转）
-	if cfg.EnableFile && cfg.FilePath != "" {
-		// 确保日志目录存在
-		dir := filepath.Dir(cfg.FilePath)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return nil, fmt.Errorf("mkdir %s: %w", dir, err)
+	if len(cfg.Sinks) > 0 {
+		l.sinks = cfg.Sinks
+	} else {
+		// 兼容旧配置：根据 EnableStdio/EnableFile 构建默认 Sink
+		if cfg.EnableStdio {
+			l.sinks = append(l.sinks, NewStdoutSink(DEBUG))
 		}
-
-		// 创建 lumberjack 日志轮转器
-		l.lumberjack = &lumberjack.Logger{
-			Filename:   cfg.FilePath,   // 日志文件路径
-			MaxSize:    cfg.MaxSize,    // 单文件最大 MB
-			MaxBackups: cfg.MaxBackups, // 保留旧文件数量
-			MaxAge:     cfg.MaxAge,     // 保留天数
-			Compress:   cfg.Compress,   // 是否压缩
-			LocalTime:  true,           // 使用本地时间命名备份文件
+		if cfg.EnableFile && cfg.FilePath != "" {
+			l.sinks = append(l.sinks, NewFileSink(DEBUG, cfg.FilePath, cfg.MaxSize, cfg.MaxBackups, cfg.MaxAge, cfg.Compress))
+		}
+		if len(l.sinks) == 0 {
+			l.sinks = append(l.sinks, NewStdoutSink(DEBUG))
 		}
-		writers = append(writers, l.lumberjack)
-	}
-
-	// 如果没有任何输出，默认使用 stdout
-	if len(writers) == 0 {
-		writers = append(writers, os.Stdout)
 	}
 
-	// 创建多输出 writer
-	multiWriter := io.MultiWriter(writers...)
-	l.logger = log.New(multiWriter, "", 0)
-
 	return l, nil
 }
 
-// Close 关闭日志器，释放文件资源
+// Close 关闭日志器，释放所有 Sink 占用的资源
 func (l *Logger) Close() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	if l.lumberjack != nil {
-		return l.lumberjack.Close()
+	var errs []string
+	for _, sink := range l.sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("close sinks: %s", strings.Join(errs, "; "))
 	}
 	return nil
 }
 
-// Rotate 手动触发日志轮转
+// Rotate 手动触发所有支持轮转的 Sink 进行轮转
 func (l *Logger) Rotate() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	if l.lumberjack != nil {
-		return l.lumberjack.Rotate()
+	var errs []string
+	for _, sink := range l.sinks {
+		if r, ok := sink.(Rotator); ok {
+			if err := r.Rotate(); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("rotate sinks: %s", strings.Join(errs, "; "))
 	}
 	return nil
 }
 
-// SetLevel 设置日志级别
+// SetLevel 设置日志级别，无锁操作，可在运行时（如管理接口）频繁调用而不影响写日志热路径
 func (l *Logger) SetLevel(level Level) {
+	l.level.Store(int32(level))
+}
+
+// With 返回携带额外上下文字段的子日志器，与父日志器共享 Sink/Hook/级别
+func (l *Logger) With(fields ...Field) *Logger {
+	child := &Logger{
+		level:   l.level,
+		format:  l.format,
+		sinks:   l.sinks,
+		hooks:   l.hooks,
+		sampler: l.sampler,
+	}
+	child.fields = make([]Field, 0, len(l.fields)+len(fields))
+	child.fields = append(child.fields, l.fields...)
+	child.fields = append(child.fields, fields...)
+	return child
+}
+
+// WithContext 从 ctx 中提取 OpenTelemetry 的 trace_id/span_id 并注入日志字段，实现日志和链路追踪的关联
+// ctx 中没有有效 span（未配置 Tracer 或未传播）时行为等同于直接返回 l 本身
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return l
+	}
+	return l.With(String("trace_id", sc.TraceID().String()), String("span_id", sc.SpanID().String()))
+}
+
+// RegisterSink 追加一个输出目标
+func (l *Logger) RegisterSink(sink Sink) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	l.level = level
+	l.sinks = append(l.sinks, sink)
 }
 
-// formatMessage 格式化日志消息
-func (l *Logger) formatMessage(level Level, format string, args ...interface{}) string {
-	timestamp := time.Now().Format("2006-01-02 15:04:05.000")
-	levelStr := levelNames[level]
-	message := fmt.Sprintf(format, args...)
-	return fmt.Sprintf("[%s] [%s] %s", timestamp, levelStr, message)
+// RegisterHook 追加一个写日志钩子
+func (l *Logger) RegisterHook(hook Hook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, hook)
+}
+
+// formatText 按现有的人类可读文本格式渲染一条日志
+func formatText(entry Entry) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] [%s] %s", entry.Time.Format("2006-01-02 15:04:05.000"), levelNames[entry.Level], entry.Message)
+	for _, f := range entry.Fields {
+		fmt.Fprintf(&b, " %s=%v", f.Key, f.Value)
+	}
+	return b.String()
 }
 
-// log 内部日志记录方法
+// formatJSON 按 JSON 行格式渲染一条日志
+func formatJSON(entry Entry) string {
+	m := make(map[string]interface{}, len(entry.Fields)+3)
+	m["time"] = entry.Time.Format(time.RFC3339Nano)
+	m["level"] = levelNames[entry.Level]
+	m["message"] = entry.Message
+	for _, f := range entry.Fields {
+		m[f.Key] = f.Value
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		// 序列化失败时退化为文本格式，保证日志不丢失
+		return formatText(entry)
+	}
+	return string(data)
+}
+
+// render 根据 Logger 的格式配置渲染一条日志
+func (l *Logger) render(entry Entry) string {
+	switch l.format {
+	case FormatJSON:
+		return formatJSON(entry)
+	default:
+		return formatText(entry)
+	}
+}
+
+// write 将一条已经构造好的 Entry 分发给所有满足级别阈值的 Sink，并触发 Hook
+func (l *Logger) write(entry Entry) {
+	for _, hook := range l.hooks {
+		entry = hook.Before(entry)
+	}
+
+	line := l.render(entry)
+
+	l.mu.Lock()
+	sinks := l.sinks
+	l.mu.Unlock()
+
+	for _, sink := range sinks {
+		if entry.Level < sink.Level() {
+			continue
+		}
+		if err := sink.Write(entry, line); err != nil {
+			for _, hook := range l.hooks {
+				hook.OnError(err)
+			}
+		}
+	}
+}
+
+// log 内部日志记录方法，兼容旧的 fmt.Sprintf 风格调用
 func (l *Logger) log(level Level, format string, args ...interface{}) {
-	if level < l.level {
+	if level < Level(l.level.Load()) {
+		return
+	}
+	if l.sampler != nil && !l.sampler.allow(level) {
 		return
 	}
 
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	l.write(Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: fmt.Sprintf(format, args...),
+		Fields:  l.fields,
+	})
+}
+
+// logw 内部结构化日志记录方法，供 Debugw/Infow/Warnw/Errorw 使用
+func (l *Logger) logw(level Level, msg string, kvs ...interface{}) {
+	if level < Level(l.level.Load()) {
+		return
+	}
+	if l.sampler != nil && !l.sampler.allow(level) {
+		return
+	}
 
-	msg := l.formatMessage(level, format, args...)
-	l.logger.Println(msg)
+	extra := fieldsFromKV(kvs...)
+	fields := make([]Field, 0, len(l.fields)+len(extra))
+	fields = append(fields, l.fields...)
+	fields = append(fields, extra...)
+
+	l.write(Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+		Fields:  fields,
+	})
 }
 
 // Debug 记录 DEBUG 级别日志
@@ -222,6 +365,26 @@ func (l *Logger) Fatal(format string, args ...interface{}) {
 	os.Exit(1)
 }
 
+// Debugw 记录携带 k/v 结构化字段的 DEBUG 日志，如 Debugw("cache miss", "key", key)
+func (l *Logger) Debugw(msg string, kvs ...interface{}) {
+	l.logw(DEBUG, msg, kvs...)
+}
+
+// Infow 记录携带 k/v 结构化字段的 INFO 日志
+func (l *Logger) Infow(msg string, kvs ...interface{}) {
+	l.logw(INFO, msg, kvs...)
+}
+
+// Warnw 记录携带 k/v 结构化字段的 WARN 日志
+func (l *Logger) Warnw(msg string, kvs ...interface{}) {
+	l.logw(WARN, msg, kvs...)
+}
+
+// Errorw 记录携带 k/v 结构化字段的 ERROR 日志
+func (l *Logger) Errorw(msg string, kvs ...interface{}) {
+	l.logw(ERROR, msg, kvs...)
+}
+
 // ========== 包级别的便捷函数 ==========
 
 // GetDefault 获取默认日志器
@@ -258,6 +421,36 @@ func Fatal(format string, args ...interface{}) {
 	GetDefault().Fatal(format, args...)
 }
 
+// Debugw 包级别携带 k/v 结构化字段的 DEBUG 日志
+func Debugw(msg string, kvs ...interface{}) {
+	GetDefault().Debugw(msg, kvs...)
+}
+
+// Infow 包级别携带 k/v 结构化字段的 INFO 日志
+func Infow(msg string, kvs ...interface{}) {
+	GetDefault().Infow(msg, kvs...)
+}
+
+// Warnw 包级别携带 k/v 结构化字段的 WARN 日志
+func Warnw(msg string, kvs ...interface{}) {
+	GetDefault().Warnw(msg, kvs...)
+}
+
+// Errorw 包级别携带 k/v 结构化字段的 ERROR 日志
+func Errorw(msg string, kvs ...interface{}) {
+	GetDefault().Errorw(msg, kvs...)
+}
+
+// With 基于默认日志器创建携带上下文字段的子日志器
+func With(fields ...Field) *Logger {
+	return GetDefault().With(fields...)
+}
+
+// WithContext 基于默认日志器创建携带 trace_id/span_id 的子日志器
+func WithContext(ctx context.Context) *Logger {
+	return GetDefault().WithContext(ctx)
+}
+
 // Close 关闭默认日志器
 func Close() error {
 	if defaultLogger != nil {
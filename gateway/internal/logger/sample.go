@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sampler 按级别对日志做简单的每秒限流（按自然秒的固定窗口计数），用于在高负载下压制 DEBUG/INFO 日志量
+// ERROR/FATAL 不做采样，避免丢失关键问题
+type sampler struct {
+	mu       sync.Mutex
+	perLevel map[Level]int64 // 每级别每秒允许的最大条数，0 表示不限制
+	counters map[Level]*int64
+	windowAt int64 // 当前计数窗口起始的 unix 秒
+}
+
+// newSampler 创建采样器，perLevel 为空表示不启用采样
+func newSampler(perLevel map[Level]int64) *sampler {
+	counters := make(map[Level]*int64, len(perLevel))
+	for lvl := range perLevel {
+		var c int64
+		counters[lvl] = &c
+	}
+	return &sampler{
+		perLevel: perLevel,
+		counters: counters,
+		windowAt: time.Now().Unix(),
+	}
+}
+
+// allow 判断该级别的日志是否允许通过，超过当前秒的限额则丢弃
+func (s *sampler) allow(level Level) bool {
+	if level >= ERROR {
+		return true
+	}
+	limit, ok := s.perLevel[level]
+	if !ok || limit <= 0 {
+		return true
+	}
+
+	now := time.Now().Unix()
+
+	s.mu.Lock()
+	if now != s.windowAt {
+		s.windowAt = now
+		for lvl := range s.counters {
+			atomic.StoreInt64(s.counters[lvl], 0)
+		}
+	}
+	s.mu.Unlock()
+
+	counter := s.counters[level]
+	return atomic.AddInt64(counter, 1) <= limit
+}
@@ -0,0 +1,200 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Entry 表示一条待写出的日志记录，供 Sink/Hook 使用
+type Entry struct {
+	Time    time.Time
+	Level   Level
+	Message string
+	Fields  []Field
+}
+
+// Hook 在每次写日志前后被调用，可用于注入/篡改字段或上报写入错误
+type Hook interface {
+	// Before 在写入前调用，返回值会替代原始 entry（可用于追加字段、脱敏等）
+	Before(entry Entry) Entry
+	// OnError 在任意 sink 写入失败时调用
+	OnError(err error)
+}
+
+// Sink 是一个独立的日志输出目标，拥有自己的级别阈值
+type Sink interface {
+	// Write 写入一条日志，level 已经在调用方按 Sink.Level() 过滤过
+	Write(entry Entry, line string) error
+	// Level 返回该 Sink 关心的最低级别
+	Level() Level
+	// Close 释放 Sink 占用的资源
+	Close() error
+}
+
+// baseSink 提供 Level()/Close() 的默认实现，具体 Sink 组合它即可
+type baseSink struct {
+	level Level
+}
+
+func (b *baseSink) Level() Level { return b.level }
+func (b *baseSink) Close() error { return nil }
+
+// StdoutSink 输出到标准输出
+type StdoutSink struct {
+	baseSink
+}
+
+// NewStdoutSink 创建标准输出 Sink
+func NewStdoutSink(level Level) *StdoutSink {
+	return &StdoutSink{baseSink{level: level}}
+}
+
+func (s *StdoutSink) Write(_ Entry, line string) error {
+	_, err := fmt.Println(line)
+	return err
+}
+
+// FileSink 基于 lumberjack 的滚动文件输出
+type FileSink struct {
+	baseSink
+	lj *lumberjack.Logger
+}
+
+// NewFileSink 创建文件 Sink，复用现有的日志轮转配置
+func NewFileSink(level Level, path string, maxSize, maxBackups, maxAge int, compress bool) *FileSink {
+	return &FileSink{
+		baseSink: baseSink{level: level},
+		lj: &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    maxSize,
+			MaxBackups: maxBackups,
+			MaxAge:     maxAge,
+			Compress:   compress,
+			LocalTime:  true,
+		},
+	}
+}
+
+func (s *FileSink) Write(_ Entry, line string) error {
+	_, err := s.lj.Write([]byte(line + "\n"))
+	return err
+}
+
+func (s *FileSink) Close() error { return s.lj.Close() }
+
+// HTTPSink 将日志以 JSON 行的形式 POST 到任意 HTTP 接收端（如 Loki、自建日志网关）
+// 不对发送做重试或缓冲：日志上报不应阻塞业务主路径，失败直接交给 Hook.OnError 处理
+type HTTPSink struct {
+	baseSink
+	url        string
+	httpClient *http.Client
+	headers    map[string]string
+}
+
+// NewHTTPSink 创建 HTTP 推送 Sink
+func NewHTTPSink(level Level, url string, headers map[string]string) *HTTPSink {
+	return &HTTPSink{
+		baseSink:   baseSink{level: level},
+		url:        url,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		headers:    headers,
+	}
+}
+
+func (s *HTTPSink) Write(_ Entry, line string) error {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewBufferString(line))
+	if err != nil {
+		return fmt.Errorf("build log push request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("push log failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("push log rejected, status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// LokiPushEntry 是 Loki HTTP push API 的最小 payload 结构
+type LokiPushEntry struct {
+	Streams []LokiStream `json:"streams"`
+}
+
+// LokiStream 对应一组共享 label 的日志行
+type LokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string        `json:"values"`
+}
+
+// LokiSink 把日志转换为 Loki push API 格式后通过 HTTPSink 发送
+type LokiSink struct {
+	*HTTPSink
+	labels map[string]string
+}
+
+// NewLokiSink 创建 Loki Sink，labels 会作为该日志流的固定标签（如 service=gateway）
+func NewLokiSink(level Level, pushURL string, labels map[string]string, headers map[string]string) *LokiSink {
+	return &LokiSink{
+		HTTPSink: NewHTTPSink(level, pushURL, headers),
+		labels:   labels,
+	}
+}
+
+func (s *LokiSink) Write(entry Entry, line string) error {
+	payload := LokiPushEntry{
+		Streams: []LokiStream{
+			{
+				Stream: s.labels,
+				Values: [][2]string{{fmt.Sprintf("%d", entry.Time.UnixNano()), line}},
+			},
+		},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal loki payload failed: %w", err)
+	}
+	return s.HTTPSink.Write(entry, string(body))
+}
+
+// KafkaProducer 是 KafkaSink 依赖的最小生产者接口
+// 不在 logger 包内直接引入具体 Kafka 客户端库，由调用方注入实现，避免日志包强依赖消息队列 SDK
+type KafkaProducer interface {
+	Produce(topic string, key, value []byte) error
+}
+
+// KafkaSink 将日志写入 Kafka topic
+type KafkaSink struct {
+	baseSink
+	producer KafkaProducer
+	topic    string
+}
+
+// NewKafkaSink 创建 Kafka Sink
+func NewKafkaSink(level Level, producer KafkaProducer, topic string) *KafkaSink {
+	return &KafkaSink{
+		baseSink: baseSink{level: level},
+		producer: producer,
+		topic:    topic,
+	}
+}
+
+func (s *KafkaSink) Write(entry Entry, line string) error {
+	key := []byte(levelNames[entry.Level])
+	if err := s.producer.Produce(s.topic, key, []byte(line)); err != nil {
+		return fmt.Errorf("produce log to kafka failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+package observability
+
+// Config 对应配置文件里的 observability.* 配置块
+type Config struct {
+	// OTLPEndpoint OpenTelemetry Collector 的 gRPC 地址，如 "otel-collector:4317"；为空时不导出 trace
+	OTLPEndpoint string
+	// ServiceName 上报给 Collector 的服务名，用于在 Tracing 后端区分不同服务
+	ServiceName string
+	// SampleRatio 采样率，取值 [0, 1]，1 表示全采样，0 表示不采样
+	SampleRatio float64
+}
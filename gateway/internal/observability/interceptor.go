@@ -0,0 +1,164 @@
+package observability
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcMetadataCarrier 把 gRPC metadata.MD 适配成 otel propagation.TextMapCarrier，
+// 用于从 incoming metadata 里提取 W3C traceparent，以及向 outgoing metadata 里注入 traceparent
+type grpcMetadataCarrier metadata.MD
+
+func (c grpcMetadataCarrier) Get(key string) string {
+	vals := metadata.MD(c).Get(key)
+	if len(vals) == 0 {
+		return ""
+	}
+	return vals[0]
+}
+
+func (c grpcMetadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c grpcMetadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// deploymentCarrier 由携带 deployment_id 的请求类型（ChatCompletionRequest、EmbeddingRequest 等）实现
+// Execute/ExecuteVector 等不涉及具体部署的请求不需要实现，这时指标按空字符串分类
+type deploymentCarrier interface {
+	GetDeploymentId() string
+}
+
+func deploymentFromRequest(req interface{}) string {
+	if dc, ok := req.(deploymentCarrier); ok {
+		return dc.GetDeploymentId()
+	}
+	return ""
+}
+
+// UnaryServerInterceptor 从 incoming metadata 提取 W3C traceparent 延续上游 trace，
+// 为每次调用开启 span，并记录 RequestsTotal/RequestDuration 指标
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = extractTraceContext(ctx)
+		ctx, span := StartSpan(ctx, info.FullMethod)
+		defer span.End()
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		duration := time.Since(start).Seconds()
+
+		deployment := deploymentFromRequest(req)
+		st, _ := status.FromError(err)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(otelcodes.Error, err.Error())
+		}
+
+		RequestsTotal.WithLabelValues(info.FullMethod, deployment, st.Code().String()).Inc()
+		RequestDuration.WithLabelValues(info.FullMethod, deployment).Observe(duration)
+
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor 对应流式 RPC（如 ChatCompletionStream）的拦截器
+// gRPC 流式调用没有单次 request 对象入参，deployment 标签统一置空，由业务内部的细粒度指标补充
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := extractTraceContext(ss.Context())
+		ctx, span := StartSpan(ctx, info.FullMethod)
+		defer span.End()
+
+		start := time.Now()
+		err := handler(srv, &tracedServerStream{ServerStream: ss, ctx: ctx})
+		duration := time.Since(start).Seconds()
+
+		st, _ := status.FromError(err)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(otelcodes.Error, err.Error())
+		}
+
+		RequestsTotal.WithLabelValues(info.FullMethod, "", st.Code().String()).Inc()
+		RequestDuration.WithLabelValues(info.FullMethod, "").Observe(duration)
+
+		return err
+	}
+}
+
+// tracedServerStream 把携带 span 的 ctx 替换进 ServerStream，供 handler 内部通过 stream.Context() 取用
+type tracedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tracedServerStream) Context() context.Context { return s.ctx }
+
+// extractTraceContext 从 gRPC incoming metadata 中解析 W3C traceparent，延续上游调用链
+func extractTraceContext(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, grpcMetadataCarrier(md))
+}
+
+// UnaryClientInterceptor 把当前 span 的 traceparent 注入 outgoing metadata，供未来的 gRPC 客户端复用
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := StartSpan(ctx, method)
+		defer span.End()
+
+		ctx = injectTraceContext(ctx)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(otelcodes.Error, err.Error())
+		}
+		return err
+	}
+}
+
+// StreamClientInterceptor 流式调用版本的客户端拦截器
+// 注意：span 只覆盖建立流的耗时，不跟踪后续每次 Send/Recv，完整的流生命周期追踪留给未来按需扩展
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, span := StartSpan(ctx, method)
+		defer span.End()
+
+		ctx = injectTraceContext(ctx)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(otelcodes.Error, err.Error())
+		}
+		return cs, err
+	}
+}
+
+// injectTraceContext 把当前 span 的 traceparent 写入 outgoing metadata
+func injectTraceContext(ctx context.Context) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		md = metadata.MD{}
+	} else {
+		md = md.Copy()
+	}
+	otel.GetTextMapPropagator().Inject(ctx, grpcMetadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
@@ -0,0 +1,79 @@
+package observability
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// 以下指标覆盖请求级（gRPC 拦截器填充）和组件内部（StorageService/LLMProxyService 填充）两个层面
+var (
+	// RequestsTotal 按 method + deployment + status 统计的请求总数
+	RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_agent_gateway_requests_total",
+		Help: "gRPC 请求总数，按方法、部署和状态码分类",
+	}, []string{"method", "deployment", "status"})
+
+	// RequestDuration 按 method + deployment 统计的请求耗时分布
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bot_agent_gateway_request_duration_seconds",
+		Help:    "gRPC 请求耗时分布",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "deployment"})
+
+	// LLMUpstreamLatency 上游 LLM 调用耗时，stage 区分首字延迟（ttft）和全量耗时（total）
+	LLMUpstreamLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bot_agent_gateway_llm_upstream_latency_seconds",
+		Help:    "上游 LLM 调用耗时，按部署和阶段（ttft/total）分类",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30, 60},
+	}, []string{"deployment", "stage"})
+
+	// TokensTotal 按部署 + 方向（prompt/completion）统计的 token 消耗总量
+	TokensTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_agent_gateway_llm_tokens_total",
+		Help: "LLM token 消耗总量，按部署和方向（prompt/completion）分类",
+	}, []string{"deployment", "direction"})
+
+	// BatchSize MySQL/Milvus 批量操作的批大小分布，按组件（mysql/milvus）分类
+	BatchSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bot_agent_gateway_storage_batch_size",
+		Help:    "存储批量操作的批大小分布",
+		Buckets: []float64{1, 2, 5, 10, 20, 50, 100, 200, 500},
+	}, []string{"component"})
+
+	// OpErrorsTotal 存储层每个操作类型的失败次数，按组件 + 操作分类
+	OpErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bot_agent_gateway_storage_op_errors_total",
+		Help: "存储层操作失败次数，按组件和操作类型分类",
+	}, []string{"component", "op"})
+)
+
+// RecordBatch 记录一次批量存储操作的规模及失败数，component 取 "mysql" 或 "milvus"
+func RecordBatch(component, op string, size, errCount int) {
+	BatchSize.WithLabelValues(component).Observe(float64(size))
+	if errCount > 0 {
+		OpErrorsTotal.WithLabelValues(component, op).Add(float64(errCount))
+	}
+}
+
+// RecordTokens 记录一次 LLM 调用的 token 消耗
+func RecordTokens(deployment string, promptTokens, completionTokens int32) {
+	if promptTokens > 0 {
+		TokensTotal.WithLabelValues(deployment, "prompt").Add(float64(promptTokens))
+	}
+	if completionTokens > 0 {
+		TokensTotal.WithLabelValues(deployment, "completion").Add(float64(completionTokens))
+	}
+}
+
+// RecordUpstreamLatencySeconds 记录一次上游调用耗时，stage 传 "ttft" 或 "total"
+func RecordUpstreamLatencySeconds(deployment, stage string, seconds float64) {
+	LLMUpstreamLatency.WithLabelValues(deployment, stage).Observe(seconds)
+}
+
+// MetricsHandler 返回 Prometheus 的 /metrics HTTP Handler
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,90 @@
+package quota
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket 是一个简单的令牌桶限流器：容量 capacity，每秒补充 refillPerSec 个令牌
+// 同时用于 RPM（容量=limit，refillPerSec=limit/60）和 TPM（容量=limit，refillPerSec=limit/60）限流
+type tokenBucket struct {
+	mu           sync.Mutex
+	capacity     float64
+	tokens       float64
+	refillPerSec float64
+	lastRefill   time.Time
+}
+
+// newTokenBucket 创建令牌桶，perMinuteLimit<=0 表示不限流（Allow 始终返回 true）
+func newTokenBucket(perMinuteLimit float64) *tokenBucket {
+	return &tokenBucket{
+		capacity:     perMinuteLimit,
+		tokens:       perMinuteLimit,
+		refillPerSec: perMinuteLimit / 60,
+		lastRefill:   time.Now(),
+	}
+}
+
+// refillLocked 按经过的时间补充令牌，调用方需持有锁
+func (b *tokenBucket) refillLocked() {
+	if b.capacity <= 0 {
+		return
+	}
+	now := time.Now()
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens += elapsed * b.refillPerSec
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.lastRefill = now
+}
+
+// tryConsume 尝试消耗 n 个令牌，成功返回 true；不限流（capacity<=0）时始终成功
+func (b *tokenBucket) tryConsume(n float64) bool {
+	if b.capacity <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refillLocked()
+	if b.tokens < n {
+		return false
+	}
+	b.tokens -= n
+	return true
+}
+
+// release 归还 n 个令牌，用于预扣后实际用量小于预估，或请求中途失败/取消需要回滚预扣
+func (b *tokenBucket) release(n float64) {
+	if b.capacity <= 0 || n <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens += n
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+// retryAfter 估算需要等待多久才能凑够 n 个令牌，用于在拒绝请求时返回 retry-after 提示
+func (b *tokenBucket) retryAfter(n float64) time.Duration {
+	if b.capacity <= 0 || b.refillPerSec <= 0 {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refillLocked()
+
+	deficit := n - b.tokens
+	if deficit <= 0 {
+		return 0
+	}
+	return time.Duration(deficit/b.refillPerSec*1000) * time.Millisecond
+}
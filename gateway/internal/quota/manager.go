@@ -0,0 +1,324 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"bot_agent/gateway/internal/config"
+	"bot_agent/gateway/internal/logger"
+)
+
+// Limits 定义一个调用方（API Key 或租户）的配额上限，<=0 表示该维度不限制
+type Limits struct {
+	RPM              int     // 每分钟请求数
+	TPM              int     // 每分钟 token 数
+	MonthlyBudgetUSD float64 // 每月美元预算
+}
+
+// ModelPrice 定义某个模型每 1000 token 的价格，用于按 Usage 折算成本
+type ModelPrice struct {
+	PromptPer1K     float64
+	CompletionPer1K float64
+}
+
+// UsageRecord 是一次提交的用量，供 Store 持久化
+type UsageRecord struct {
+	Key              string
+	Model            string
+	PromptTokens     int64
+	CompletionTokens int64
+	CostUSD          float64
+	RecordedAt       time.Time
+}
+
+// Store 持久化用量记录，由 storage 包实现（写 MySQL quota_usage 表）
+type Store interface {
+	FlushUsage(ctx context.Context, records []UsageRecord) error
+}
+
+// usageState 是某个 key 在内存中的累计使用情况，定期 flush 后清零增量部分
+type usageState struct {
+	rpm          *tokenBucket
+	tpm          *tokenBucket
+	month        string  // monthlyCost 所属的自然月（UTC，格式 2006-01），跨月时清零重新计起
+	monthlyCost  float64 // 当月累计成本，用于配额判断
+	pendingUsage []UsageRecord
+}
+
+// resetIfNewMonthLocked 按 UTC 自然月把 monthlyCost 清零，调用方必须持有 Manager.mu
+func (s *usageState) resetIfNewMonthLocked() {
+	month := time.Now().UTC().Format("2006-01")
+	if s.month != month {
+		s.month = month
+		s.monthlyCost = 0
+	}
+}
+
+// Reservation 表示一次预扣，ChatCompletion 等调用结束后需要 Commit 或 Rollback
+type Reservation struct {
+	key            string
+	reservedTokens float64
+}
+
+// Manager 是配额子系统的入口：按 key 维护 RPM/TPM 令牌桶和月度成本，定期把增量 flush 到 Store
+type Manager struct {
+	mu         sync.Mutex
+	defaultLim Limits
+	overrides  map[string]Limits // 按 key 覆盖默认限额（管理接口可运行时更新）
+	prices     map[string]ModelPrice
+	states     map[string]*usageState
+	store      Store
+	flushEvery time.Duration
+	stopCh     chan struct{}
+}
+
+// NewManager 创建配额管理器，prices 为模型价目表，flushEvery<=0 时使用默认 1 分钟
+func NewManager(defaultLim Limits, prices map[string]ModelPrice, store Store, flushEvery time.Duration) *Manager {
+	if flushEvery <= 0 {
+		flushEvery = time.Minute
+	}
+	m := &Manager{
+		defaultLim: defaultLim,
+		overrides:  make(map[string]Limits),
+		prices:     prices,
+		states:     make(map[string]*usageState),
+		store:      store,
+		flushEvery: flushEvery,
+		stopCh:     make(chan struct{}),
+	}
+	go m.flushLoop()
+	return m
+}
+
+// limitsFor 返回某个 key 生效的限额：优先使用管理接口设置的覆盖值，否则用默认值
+func (m *Manager) limitsFor(key string) Limits {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if lim, ok := m.overrides[key]; ok {
+		return lim
+	}
+	return m.defaultLim
+}
+
+// SetLimits 运行时更新某个 key 的限额，供管理接口（LLMProxyService.AdminSetQuota）调用
+func (m *Manager) SetLimits(key string, lim Limits) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.overrides[key] = lim
+	if state, ok := m.states[key]; ok {
+		state.rpm = newTokenBucket(float64(lim.RPM))
+		state.tpm = newTokenBucket(float64(lim.TPM))
+	}
+}
+
+// GetLimits 查询某个 key 当前生效的限额，供管理接口（LLMProxyService.AdminGetQuota）调用
+func (m *Manager) GetLimits(key string) Limits {
+	return m.limitsFor(key)
+}
+
+// stateFor 获取或创建某个 key 的内存状态
+func (m *Manager) stateFor(key string) *usageState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if state, ok := m.states[key]; ok {
+		return state
+	}
+
+	lim := m.defaultLim
+	if ov, ok := m.overrides[key]; ok {
+		lim = ov
+	}
+	state := &usageState{
+		rpm: newTokenBucket(float64(lim.RPM)),
+		tpm: newTokenBucket(float64(lim.TPM)),
+	}
+	m.states[key] = state
+	return state
+}
+
+// QuotaExceededError 描述一次被拒绝的请求及建议的重试等待时间
+type QuotaExceededError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("quota exceeded: %s (retry after %s)", e.Reason, e.RetryAfter)
+}
+
+// Reserve 在调用上游 LLM 之前做一次配额预检：RPM 按 1 个请求计，TPM 按 estimatedTokens 预估值预扣
+// 预扣失败时返回 *QuotaExceededError，调用方应据此返回 gRPC ResourceExhausted
+func (m *Manager) Reserve(key string, estimatedTokens int32, model string) (*Reservation, error) {
+	state := m.stateFor(key)
+	lim := m.limitsFor(key)
+
+	if !state.rpm.tryConsume(1) {
+		return nil, &QuotaExceededError{Reason: "rpm limit exceeded", RetryAfter: state.rpm.retryAfter(1)}
+	}
+
+	tokens := float64(estimatedTokens)
+	if !state.tpm.tryConsume(tokens) {
+		state.rpm.release(1)
+		return nil, &QuotaExceededError{Reason: "tpm limit exceeded", RetryAfter: state.tpm.retryAfter(tokens)}
+	}
+
+	if lim.MonthlyBudgetUSD > 0 {
+		estimatedCost := m.estimateCost(model, estimatedTokens, 0)
+		m.mu.Lock()
+		state.resetIfNewMonthLocked()
+		projected := state.monthlyCost + estimatedCost
+		m.mu.Unlock()
+		if projected > lim.MonthlyBudgetUSD {
+			state.rpm.release(1)
+			state.tpm.release(tokens)
+			return nil, &QuotaExceededError{Reason: "monthly budget exceeded", RetryAfter: 0}
+		}
+	}
+
+	return &Reservation{key: key, reservedTokens: tokens}, nil
+}
+
+// estimateCost 按价目表折算成本，未配置价格的模型按 0 计（不阻塞请求，只是不计费）
+func (m *Manager) estimateCost(model string, promptTokens, completionTokens int32) float64 {
+	price, ok := m.prices[model]
+	if !ok {
+		return 0
+	}
+	return float64(promptTokens)/1000*price.PromptPer1K + float64(completionTokens)/1000*price.CompletionPer1K
+}
+
+// Commit 用实际 Usage 核算用量：按预估值与实际值的差额调整 TPM 桶，并累加月度成本、记录待 flush 的用量
+func (m *Manager) Commit(res *Reservation, model string, promptTokens, completionTokens int32) {
+	if res == nil {
+		return
+	}
+	state := m.stateFor(res.key)
+
+	actual := float64(promptTokens + completionTokens)
+	if diff := res.reservedTokens - actual; diff > 0 {
+		// 实际消耗小于预估，归还差额
+		state.tpm.release(diff)
+	} else if diff < 0 {
+		// 实际消耗大于预估，尽量补扣（令牌不足也不追溯拒绝，避免惩罚已经发生的调用）
+		state.tpm.tryConsume(-diff)
+	}
+
+	cost := m.estimateCost(model, promptTokens, completionTokens)
+
+	m.mu.Lock()
+	state.resetIfNewMonthLocked()
+	state.monthlyCost += cost
+	state.pendingUsage = append(state.pendingUsage, UsageRecord{
+		Key:              res.key,
+		Model:            model,
+		PromptTokens:     int64(promptTokens),
+		CompletionTokens: int64(completionTokens),
+		CostUSD:          cost,
+		RecordedAt:       time.Now(),
+	})
+	m.mu.Unlock()
+}
+
+// Rollback 在请求未能完成（如流式调用被客户端提前取消）时归还预扣的 TPM 令牌
+func (m *Manager) Rollback(res *Reservation) {
+	if res == nil {
+		return
+	}
+	state := m.stateFor(res.key)
+	state.tpm.release(res.reservedTokens)
+}
+
+// flushLoop 周期性地把各 key 累积的用量写入 Store，重启不丢失计费数据
+func (m *Manager) flushLoop() {
+	ticker := time.NewTicker(m.flushEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.flush()
+		case <-m.stopCh:
+			m.flush()
+			return
+		}
+	}
+}
+
+// flush 把所有 key 的待持久化用量一次性写入 Store
+func (m *Manager) flush() {
+	if m.store == nil {
+		return
+	}
+
+	m.mu.Lock()
+	var all []UsageRecord
+	for _, state := range m.states {
+		if len(state.pendingUsage) == 0 {
+			continue
+		}
+		all = append(all, state.pendingUsage...)
+		state.pendingUsage = nil
+	}
+	m.mu.Unlock()
+
+	if len(all) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := m.store.FlushUsage(ctx, all); err != nil {
+		logger.Error("flush quota usage failed: %v", err)
+	}
+}
+
+// Close 停止后台 flush 协程，并做最后一次 flush
+func (m *Manager) Close() {
+	close(m.stopCh)
+}
+
+// LoadPricesFromConfig 从通用配置 map 中解析价目表，复用和 llmproxy.LoadPricingFromConfig 相同的
+// metrics.pricing 配置块，避免配额月度预算核算和 Metrics 成本统计用两份互相漂移的单价
+func LoadPricesFromConfig(cfg map[string]interface{}) (map[string]ModelPrice, error) {
+	raw := config.GetRaw(cfg, "metrics.pricing")
+	if raw == nil {
+		return nil, nil
+	}
+
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("metrics.pricing must be a list")
+	}
+
+	prices := make(map[string]ModelPrice, len(list))
+	for i, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("metrics.pricing[%d] must be an object", i)
+		}
+		model, _ := m["model"].(string)
+		if model == "" {
+			return nil, fmt.Errorf("metrics.pricing[%d] missing model", i)
+		}
+		prices[model] = ModelPrice{
+			PromptPer1K:     floatField(m, "input_usd_per_1k"),
+			CompletionPer1K: floatField(m, "output_usd_per_1k"),
+		}
+	}
+
+	return prices, nil
+}
+
+func floatField(m map[string]interface{}, key string) float64 {
+	switch v := m[key].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	default:
+		return 0
+	}
+}
@@ -6,8 +6,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
 	"github.com/milvus-io/milvus-sdk-go/v2/entity"
@@ -18,11 +20,21 @@ type MilvusClient struct {
 	dbPath     string
 	dimension  int
 	collection string // 默认 collection 名称
+	cfg        map[string]interface{} // 通用配置，用于按 collection 解析 IndexProfile
+
+	schemaMu    sync.RWMutex
+	schemaCache map[string]*entity.Schema // collection -> schema，懒加载，schema 变更时失效
+
+	indexProfileMu    sync.RWMutex
+	indexProfileCache map[string]*IndexProfile // collection -> 通过 DescribeIndex 自动探测的 profile
 }
 
 // NewMilvusClient 使用 context.Background() 初始化连接
 // collection 参数指定默认使用的 collection 名称
-func NewMilvusClient(dbPath string, dimension int, collection string) (*MilvusClient, error) {
+// spec 为 nil 时维持旧行为：Collection 必须提前创建好，否则返回错误；
+// spec 非 nil 时，Collection 不存在会按 spec 自动创建 schema、建索引并加载
+// cfg 用于解析 milvus.index_profiles 等按 collection 配置的参数，可以为 nil
+func NewMilvusClient(dbPath string, dimension int, collection string, spec *CollectionSpec, cfg map[string]interface{}) (*MilvusClient, error) {
 	ctx := context.Background()
 
 	c, err := client.NewClient(ctx, client.Config{
@@ -38,14 +50,17 @@ func NewMilvusClient(dbPath string, dimension int, collection string) (*MilvusCl
 	}
 
 	m := &MilvusClient{
-		client:     c,
-		dbPath:     dbPath,
-		dimension:  dimension,
-		collection: collection,
+		client:            c,
+		dbPath:            dbPath,
+		dimension:         dimension,
+		collection:        collection,
+		cfg:               cfg,
+		schemaCache:       make(map[string]*entity.Schema),
+		indexProfileCache: make(map[string]*IndexProfile),
 	}
 
-	// 初始化配置的 Collection
-	if err := m.ensureCollection(ctx, collection); err != nil {
+	// 初始化配置的 Collection，缺失时按 spec 自动创建
+	if err := m.ensureCollection(ctx, collection, spec); err != nil {
 		c.Close()
 		return nil, fmt.Errorf("failed to ensure collection: %w", err)
 	}
@@ -87,6 +102,8 @@ func (m *MilvusClient) ExecuteBatch(ctx context.Context, ops []*pb.VectorOperati
 			res = m.executeDelete(ctx, op, i)
 		case *pb.VectorOperation_Upsert:
 			res = m.executeUpsert(ctx, op, i)
+		case *pb.VectorOperation_HybridSearch:
+			res = m.executeHybridSearch(ctx, op, i)
 		default:
 			res = &pb.VectorOperationResult{
 				Index:   int32(i),
@@ -277,8 +294,18 @@ func (m *MilvusClient) executeSearch(ctx context.Context, op *pb.VectorOperation
 	// 构建查询向量
 	queryVectors := []entity.Vector{entity.FloatVector(searchOp.QueryVector)}
 
-	// 构建搜索参数
-	sp, err := entity.NewIndexIvfFlatSearchParam(16) // nprobe 参数
+	// 解析该 collection 应使用的索引 profile：请求显式覆盖 > milvus.index_profiles 配置 >
+	// DescribeIndex 自动探测，最终按 profile.IndexType 构建对应的 SearchParam
+	profile, err := m.resolveIndexProfile(ctx, collection, searchIndexProfileOverride(searchOp))
+	if err != nil {
+		return &pb.VectorOperationResult{
+			Index:   int32(index),
+			Success: false,
+			Error:   fmt.Sprintf("resolve index profile error: %v", err),
+		}
+	}
+
+	sp, err := buildSearchParam(profile)
 	if err != nil {
 		return &pb.VectorOperationResult{
 			Index:   int32(index),
@@ -287,8 +314,28 @@ func (m *MilvusClient) executeSearch(ctx context.Context, op *pb.VectorOperation
 		}
 	}
 
+	// Range search：限定结果落在 [range_filter, radius) 距离band 内，而不是固定 top-K；
+	// 只有底层 SearchParam 实现了 AddRadius/AddRangeFilter 才会生效，不支持的索引类型静默忽略
+	if searchOp.Radius != 0 || searchOp.RangeFilter != 0 {
+		if rsp, ok := sp.(rangeSearchParam); ok {
+			if searchOp.Radius != 0 {
+				rsp.AddRadius(float64(searchOp.Radius))
+			}
+			if searchOp.RangeFilter != 0 {
+				rsp.AddRangeFilter(float64(searchOp.RangeFilter))
+			}
+		}
+	}
+
 	// 构建过滤表达式
-	filterExpr := m.buildFilterExpr(searchOp.Filter, searchOp.FilterExpr)
+	filterExpr, err := m.buildFilterExpr(searchOp.FilterNode, searchOp.Filter, searchOp.FilterExpr)
+	if err != nil {
+		return &pb.VectorOperationResult{
+			Index:   int32(index),
+			Success: false,
+			Error:   fmt.Sprintf("build filter expr error: %v", err),
+		}
+	}
 
 	// 设置 top_k
 	topK := int(searchOp.TopK)
@@ -296,10 +343,18 @@ func (m *MilvusClient) executeSearch(ctx context.Context, op *pb.VectorOperation
 		topK = 10 // 默认值
 	}
 
-	// 设置输出字段
+	// 设置输出字段，展开 "*" (全部标量字段) 和 "%" (全部向量字段) 通配符
 	outputFields := searchOp.OutputFields
 	if len(outputFields) == 0 {
-		outputFields = []string{"*"} // 返回所有字段
+		outputFields = []string{"*"}
+	}
+	outputFields, err = m.resolveOutputFields(ctx, collection, outputFields)
+	if err != nil {
+		return &pb.VectorOperationResult{
+			Index:   int32(index),
+			Success: false,
+			Error:   fmt.Sprintf("resolve output fields error: %v", err),
+		}
 	}
 
 	// 构建分区列表
@@ -308,6 +363,12 @@ func (m *MilvusClient) executeSearch(ctx context.Context, op *pb.VectorOperation
 		partitions = []string{op.Partition}
 	}
 
+	// Group-by search：按标量字段去重，每个分组最多返回一条命中
+	var searchOpts []client.SearchQueryOptionFunc
+	if searchOp.GroupByField != "" {
+		searchOpts = append(searchOpts, client.WithGroupByField(searchOp.GroupByField))
+	}
+
 	// 执行搜索
 	searchResult, err := m.client.Search(
 		ctx,
@@ -317,11 +378,16 @@ func (m *MilvusClient) executeSearch(ctx context.Context, op *pb.VectorOperation
 		outputFields,
 		queryVectors,
 		"vector", // 向量字段名
-		entity.L2,
+		profile.MetricType,
 		topK,
 		sp,
+		searchOpts...,
 	)
 	if err != nil {
+		// schema 可能在运行期间变更（加字段/删字段），令缓存失效以便下次请求重新拉取
+		if isSchemaChangeError(err) {
+			m.invalidateSchemaCache(collection)
+		}
 		return &pb.VectorOperationResult{
 			Index:   int32(index),
 			Success: false,
@@ -329,7 +395,22 @@ func (m *MilvusClient) executeSearch(ctx context.Context, op *pb.VectorOperation
 		}
 	}
 
-	// 解析搜索结果
+	matches := m.parseSearchMatches(searchResult, searchOp.MinScore)
+
+	return &pb.VectorOperationResult{
+		Index:   int32(index),
+		Success: true,
+		Result: &pb.VectorOperationResult_SearchResult{
+			SearchResult: &pb.VectorSearchResult{
+				Matches: matches,
+			},
+		},
+	}
+}
+
+// parseSearchMatches 把 SDK 返回的 SearchResult 解析成 VectorMatch 列表，被单路 Search 和
+// hybrid search 的每个子查询共用
+func (m *MilvusClient) parseSearchMatches(searchResult []client.SearchResult, minScore float32) []*pb.VectorMatch {
 	matches := make([]*pb.VectorMatch, 0)
 	for _, result := range searchResult {
 		for i := 0; i < result.ResultCount; i++ {
@@ -357,16 +438,16 @@ func (m *MilvusClient) executeSearch(ctx context.Context, op *pb.VectorOperation
 			}
 
 			// 过滤最小分数阈值
-			if searchOp.MinScore > 0 && score < searchOp.MinScore {
+			if minScore > 0 && score < minScore {
 				continue
 			}
 
-			// 获取元数据
+			// 获取元数据；向量字段仅在调用方通过 "%" 或显式字段名请求时才会出现在 result.Fields 中
 			metadata := make(map[string]*pb.TypedValue)
 			for _, field := range result.Fields {
 				fieldName := field.Name()
-				if fieldName == "vector" || fieldName == "id" {
-					continue // 跳过向量和 ID 字段
+				if fieldName == "id" {
+					continue // 跳过 ID 字段，已经在 Id 里返回
 				}
 				if i < field.Len() {
 					metadata[fieldName] = m.fieldToTypedValue(field, i)
@@ -380,18 +461,337 @@ func (m *MilvusClient) executeSearch(ctx context.Context, op *pb.VectorOperation
 			})
 		}
 	}
+	return matches
+}
+
+// rangeSearchParam 是部分索引类型的 SearchParam 支持的可选 range search 能力；
+// 不是所有索引（如 DISKANN）都支持 radius/range_filter，不满足该接口时静默忽略
+type rangeSearchParam interface {
+	entity.SearchParam
+	AddRadius(radius float64)
+	AddRangeFilter(rangeFilter float64)
+}
+
+// hybridSearchCapable 是底层 milvus-sdk-go client.Client 对多向量混合检索的可选能力接口；
+// 不是所有 SDK 版本都实现了它，executeHybridSearch 在拿不到该能力时会退化为 N 路并行 Search
+type hybridSearchCapable interface {
+	HybridSearch(ctx context.Context, collName string, partitions []string, limit int, outputFields []string, reranker client.Reranker, subRequests []*client.ANNSearchRequest, opts ...client.SearchQueryOptionFunc) ([]client.SearchResult, error)
+}
+
+// executeHybridSearch 执行多向量混合检索：底层 client 实现了 hybridSearchCapable 时先走原生 HybridSearch，
+// 由服务端融合；不支持或原生调用报错时，对每个 AnnRequest 并行发起一路 ANN 查询，再按 Reranker
+// 配置（RRF 或加权线性）在客户端侧融合结果，取 Top-K 返回
+func (m *MilvusClient) executeHybridSearch(ctx context.Context, op *pb.VectorOperation, index int) *pb.VectorOperationResult {
+	collection := op.GetCollection()
+	if collection == "" {
+		return &pb.VectorOperationResult{
+			Index:   int32(index),
+			Success: false,
+			Error:   "collection is empty",
+		}
+	}
+
+	hybridOp := op.GetHybridSearch()
+	if hybridOp == nil || len(hybridOp.Requests) == 0 {
+		return &pb.VectorOperationResult{
+			Index:   int32(index),
+			Success: false,
+			Error:   "hybrid search requests is empty",
+		}
+	}
+
+	if err := m.ensureCollectionLoaded(ctx, collection); err != nil {
+		return &pb.VectorOperationResult{
+			Index:   int32(index),
+			Success: false,
+			Error:   fmt.Sprintf("load collection error: %v", err),
+		}
+	}
+
+	partitions := []string{}
+	if op.Partition != "" {
+		partitions = []string{op.Partition}
+	}
+
+	topK := int(hybridOp.TopK)
+	if topK <= 0 {
+		topK = 10
+	}
+
+	// 优先使用 SDK 原生 HybridSearch（服务端融合，省一轮客户端并行查询）
+	if hc, ok := m.client.(hybridSearchCapable); ok {
+		if matches, err := m.runNativeHybridSearch(ctx, hc, collection, partitions, hybridOp, topK); err == nil {
+			return &pb.VectorOperationResult{
+				Index:   int32(index),
+				Success: true,
+				Result: &pb.VectorOperationResult_HybridSearchResult{
+					HybridSearchResult: &pb.VectorHybridSearchResult{
+						Matches: matches,
+					},
+				},
+			}
+		}
+		// 原生接口报错（如版本不支持该组合），降级为客户端侧并行 Search + 融合
+	}
+
+	perQuery := make([][]*pb.VectorMatch, len(hybridOp.Requests))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	for qi, req := range hybridOp.Requests {
+		wg.Add(1)
+		go func(qi int, req *pb.AnnRequest) {
+			defer wg.Done()
+			matches, err := m.runAnnSearch(ctx, collection, partitions, req)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+			perQuery[qi] = matches
+		}(qi, req)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return &pb.VectorOperationResult{
+			Index:   int32(index),
+			Success: false,
+			Error:   fmt.Sprintf("ann request error: %v", firstErr),
+		}
+	}
+
+	fused := fuseRerank(perQuery, hybridOp.Reranker, topK)
 
 	return &pb.VectorOperationResult{
 		Index:   int32(index),
 		Success: true,
-		Result: &pb.VectorOperationResult_SearchResult{
-			SearchResult: &pb.VectorSearchResult{
-				Matches: matches,
+		Result: &pb.VectorOperationResult_HybridSearchResult{
+			HybridSearchResult: &pb.VectorHybridSearchResult{
+				Matches: fused,
 			},
 		},
 	}
 }
 
+// runNativeHybridSearch 走 SDK 原生的 HybridSearch 接口，由 Milvus 服务端完成多路 ANN 查询和融合
+func (m *MilvusClient) runNativeHybridSearch(ctx context.Context, hc hybridSearchCapable, collection string, partitions []string, hybridOp *pb.HybridSearchOperation, topK int) ([]*pb.VectorMatch, error) {
+	subRequests := make([]*client.ANNSearchRequest, 0, len(hybridOp.Requests))
+	for _, req := range hybridOp.Requests {
+		vectorField := req.VectorField
+		if vectorField == "" {
+			vectorField = "vector"
+		}
+		subTopK := int(req.TopK)
+		if subTopK <= 0 {
+			subTopK = topK
+		}
+		sp, err := entity.NewIndexIvfFlatSearchParam(16)
+		if err != nil {
+			return nil, err
+		}
+		filterExpr, err := m.buildFilterExpr(req.FilterNode, req.Filter, req.FilterExpr)
+		if err != nil {
+			return nil, err
+		}
+		subRequests = append(subRequests, client.NewANNSearchRequest(
+			vectorField,
+			metricFromString(req.Metric),
+			filterExpr,
+			[]entity.Vector{entity.FloatVector(req.QueryVector)},
+			sp,
+			subTopK,
+		))
+	}
+
+	reranker, err := buildReranker(hybridOp.Reranker)
+	if err != nil {
+		return nil, err
+	}
+
+	searchResult, err := hc.HybridSearch(ctx, collection, partitions, topK, []string{"*"}, reranker, subRequests)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.parseSearchMatches(searchResult, 0), nil
+}
+
+// runAnnSearch 执行单路 ANN 查询，供 executeHybridSearch 在没有原生 HybridSearch 能力时并行调用
+func (m *MilvusClient) runAnnSearch(ctx context.Context, collection string, partitions []string, req *pb.AnnRequest) ([]*pb.VectorMatch, error) {
+	if len(req.QueryVector) == 0 {
+		return nil, fmt.Errorf("ann request query vector is empty")
+	}
+
+	vectorField := req.VectorField
+	if vectorField == "" {
+		vectorField = "vector"
+	}
+
+	topK := int(req.TopK)
+	if topK <= 0 {
+		topK = 10
+	}
+
+	sp, err := entity.NewIndexIvfFlatSearchParam(16)
+	if err != nil {
+		return nil, fmt.Errorf("create search param error: %w", err)
+	}
+
+	filterExpr, err := m.buildFilterExpr(req.FilterNode, req.Filter, req.FilterExpr)
+	if err != nil {
+		return nil, fmt.Errorf("build filter expr error: %w", err)
+	}
+
+	searchResult, err := m.client.Search(
+		ctx,
+		collection,
+		partitions,
+		filterExpr,
+		[]string{"*"},
+		[]entity.Vector{entity.FloatVector(req.QueryVector)},
+		vectorField,
+		metricFromString(req.Metric),
+		topK,
+		sp,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.parseSearchMatches(searchResult, 0), nil
+}
+
+// metricFromString 把请求里的 metric 字符串映射为 entity.MetricType，未知值按 L2 处理
+func metricFromString(metric string) entity.MetricType {
+	switch strings.ToUpper(metric) {
+	case "IP":
+		return entity.IP
+	case "COSINE":
+		return entity.COSINE
+	case "L2":
+		return entity.L2
+	default:
+		return entity.L2
+	}
+}
+
+// buildReranker 把 pb.RerankerConfig 转换为 SDK 的 client.Reranker：RRF 需要常数 k，
+// 加权线性需要每路查询的权重，数量必须与 ANN 子查询数量一致
+func buildReranker(cfg *pb.RerankerConfig) (client.Reranker, error) {
+	if cfg == nil {
+		return client.NewRRFReranker(60), nil // 默认 RRF，k=60 是社区常用的经验值
+	}
+	switch r := cfg.GetReranker().(type) {
+	case *pb.RerankerConfig_Rrf:
+		k := r.Rrf.K
+		if k <= 0 {
+			k = 60
+		}
+		return client.NewRRFReranker(int(k)), nil
+	case *pb.RerankerConfig_Weighted:
+		return client.NewWeightedReranker(r.Weighted.Weights), nil
+	default:
+		return nil, fmt.Errorf("unknown reranker type")
+	}
+}
+
+// fuseRerank 在客户端侧按 Reranker 配置融合多路 ANN 结果，取 Top-K：
+//   - RRF: score(id) = Σ 1/(k + rank_i(id))，rank 从 1 开始计数
+//   - Weighted: score(id) = Σ w_i * normalized_score_i(id)，每路结果先做 min-max 归一化
+func fuseRerank(perQuery [][]*pb.VectorMatch, cfg *pb.RerankerConfig, topK int) []*pb.VectorMatch {
+	if cfg != nil {
+		if w := cfg.GetWeighted(); w != nil {
+			return fuseWeighted(perQuery, w.Weights, topK)
+		}
+	}
+	k := int32(60)
+	if cfg != nil {
+		if rrf := cfg.GetRrf(); rrf != nil && rrf.K > 0 {
+			k = rrf.K
+		}
+	}
+	return fuseRRF(perQuery, k, topK)
+}
+
+// fuseRRF 实现 Reciprocal Rank Fusion：score(id) = Σ 1/(k + rank_i(id))
+func fuseRRF(perQuery [][]*pb.VectorMatch, k int32, topK int) []*pb.VectorMatch {
+	scores := make(map[string]float32)
+	best := make(map[string]*pb.VectorMatch)
+	for _, matches := range perQuery {
+		for rank, match := range matches {
+			scores[match.Id] += 1.0 / float32(int(k)+rank+1)
+			if _, ok := best[match.Id]; !ok {
+				best[match.Id] = match
+			}
+		}
+	}
+	return topFusedMatches(scores, best, topK)
+}
+
+// fuseWeighted 实现加权线性融合：每路结果先按其自身 min/max 做归一化，再乘以对应权重求和；
+// weights 数量必须和 perQuery 一一对应，缺失的权重按 1.0 处理
+func fuseWeighted(perQuery [][]*pb.VectorMatch, weights []float32, topK int) []*pb.VectorMatch {
+	scores := make(map[string]float32)
+	best := make(map[string]*pb.VectorMatch)
+	for qi, matches := range perQuery {
+		if len(matches) == 0 {
+			continue
+		}
+		weight := float32(1.0)
+		if qi < len(weights) {
+			weight = weights[qi]
+		}
+
+		min, max := matches[0].Score, matches[0].Score
+		for _, match := range matches {
+			if match.Score < min {
+				min = match.Score
+			}
+			if match.Score > max {
+				max = match.Score
+			}
+		}
+
+		for _, match := range matches {
+			normalized := float32(1.0)
+			if max > min {
+				normalized = (match.Score - min) / (max - min)
+			}
+			scores[match.Id] += weight * normalized
+			if _, ok := best[match.Id]; !ok {
+				best[match.Id] = match
+			}
+		}
+	}
+	return topFusedMatches(scores, best, topK)
+}
+
+// topFusedMatches 按融合后的分数降序排列并截取 Top-K，复用原始 match 里的 metadata
+func topFusedMatches(scores map[string]float32, best map[string]*pb.VectorMatch, topK int) []*pb.VectorMatch {
+	fused := make([]*pb.VectorMatch, 0, len(scores))
+	for id, score := range scores {
+		match := best[id]
+		fused = append(fused, &pb.VectorMatch{
+			Id:       id,
+			Score:    score,
+			Metadata: match.Metadata,
+		})
+	}
+
+	sort.Slice(fused, func(i, j int) bool {
+		return fused[i].Score > fused[j].Score
+	})
+
+	if topK > 0 && len(fused) > topK {
+		fused = fused[:topK]
+	}
+	return fused
+}
+
 // executeDelete 执行向量删除操作
 func (m *MilvusClient) executeDelete(ctx context.Context, op *pb.VectorOperation, index int) *pb.VectorOperationResult {
 	collection := op.GetCollection()
@@ -432,7 +832,14 @@ func (m *MilvusClient) executeDelete(ctx context.Context, op *pb.VectorOperation
 		deletedCount = len(deleteOp.Ids)
 	} else {
 		// 使用过滤表达式删除
-		filterExpr := m.buildFilterExpr(deleteOp.Filter, deleteOp.FilterExpr)
+		filterExpr, err := m.buildFilterExpr(deleteOp.FilterNode, deleteOp.Filter, deleteOp.FilterExpr)
+		if err != nil {
+			return &pb.VectorOperationResult{
+				Index:   int32(index),
+				Success: false,
+				Error:   fmt.Sprintf("build filter expr error: %v", err),
+			}
+		}
 		if filterExpr == "" {
 			return &pb.VectorOperationResult{
 				Index:   int32(index),
@@ -470,20 +877,23 @@ func (m *MilvusClient) executeDelete(ctx context.Context, op *pb.VectorOperation
 }
 
 // ensureCollection 确保 Collection 存在
-// 注意：此方法只检查 Collection 是否存在，不会自动创建
-// 用户需要提前通过 Milvus 管理工具或 API 创建 Collection 并定义 schema
-// 这样可以保持 Milvus 客户端的通用性，不 hardcode 任何业务字段
-func (m *MilvusClient) ensureCollection(ctx context.Context, collectionName string) error {
+// spec 为 nil 时只检查存在性，不存在则报错，要求用户提前通过 Milvus 管理工具或 API 创建好 schema；
+// spec 非 nil 时，不存在就按 spec 自动建 Collection、建索引并加载，详见 createCollectionFromSpec
+func (m *MilvusClient) ensureCollection(ctx context.Context, collectionName string, spec *CollectionSpec) error {
 	exists, err := m.client.HasCollection(ctx, collectionName)
 	if err != nil {
 		return fmt.Errorf("failed to check collection: %w", err)
 	}
 
-	if !exists {
-		return fmt.Errorf("collection '%s' does not exist, please create it first with your desired schema", collectionName)
+	if exists {
+		return nil
 	}
 
-	return nil
+	if spec == nil {
+		return fmt.Errorf("collection '%s' does not exist, please create it first with your desired schema or configure a CollectionSpec", collectionName)
+	}
+
+	return m.createCollectionFromSpec(ctx, collectionName, spec)
 }
 
 // ensurePartition 确保分区存在
@@ -521,6 +931,95 @@ func (m *MilvusClient) ensureCollectionLoaded(ctx context.Context, collection st
 	return nil
 }
 
+// getCollectionSchema 返回 collection 的 schema，优先读取缓存；未命中时通过 DescribeCollection 拉取并缓存
+func (m *MilvusClient) getCollectionSchema(ctx context.Context, collection string) (*entity.Schema, error) {
+	m.schemaMu.RLock()
+	schema, ok := m.schemaCache[collection]
+	m.schemaMu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	coll, err := m.client.DescribeCollection(ctx, collection)
+	if err != nil {
+		return nil, fmt.Errorf("describe collection failed: %w", err)
+	}
+
+	m.schemaMu.Lock()
+	m.schemaCache[collection] = coll.Schema
+	m.schemaMu.Unlock()
+
+	return coll.Schema, nil
+}
+
+// invalidateSchemaCache 清除某个 collection 的缓存 schema，在 Search 因 schema 变更报错时调用，
+// 下次 resolveOutputFields 会重新通过 DescribeCollection 拉取最新 schema
+func (m *MilvusClient) invalidateSchemaCache(collection string) {
+	m.schemaMu.Lock()
+	delete(m.schemaCache, collection)
+	m.schemaMu.Unlock()
+}
+
+// resolveOutputFields 展开 outputFields 中的通配符：
+//
+//	"*" 展开为 schema 中的全部标量字段（不含向量字段）
+//	"%" 展开为 schema 中的全部向量字段
+//
+// 二者可以和显式字段名混用：通配符展开的字段按 schema 中的字段顺序排在前面，显式字段名按传入顺序追加在后，
+// 整体去重，例如 ["*","%"] 等价于全部字段，
+// ["*", "my_vec"] 等价于全部标量字段加上 my_vec 这一个向量字段
+func (m *MilvusClient) resolveOutputFields(ctx context.Context, collection string, outputFields []string) ([]string, error) {
+	hasScalarWildcard := false
+	hasVectorWildcard := false
+	explicit := make(map[string]bool)
+	for _, f := range outputFields {
+		switch f {
+		case "*":
+			hasScalarWildcard = true
+		case "%":
+			hasVectorWildcard = true
+		default:
+			explicit[f] = true
+		}
+	}
+
+	if !hasScalarWildcard && !hasVectorWildcard {
+		return outputFields, nil
+	}
+
+	schema, err := m.getCollectionSchema(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := make([]string, 0, len(schema.Fields)+len(explicit))
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			resolved = append(resolved, name)
+		}
+	}
+
+	for _, field := range schema.Fields {
+		isVector := field.DataType == entity.FieldTypeFloatVector || field.DataType == entity.FieldTypeBinaryVector
+		switch {
+		case isVector && hasVectorWildcard:
+			add(field.Name)
+		case !isVector && hasScalarWildcard:
+			add(field.Name)
+		}
+	}
+
+	for _, name := range outputFields {
+		if name != "*" && name != "%" {
+			add(name)
+		}
+	}
+
+	return resolved, nil
+}
+
 // buildColumns 根据 VectorData 动态构建 Milvus 列数据
 // 不做任何 hardcode，完全根据用户传递的元数据字段动态构建
 // 用户需要确保传递的字段与 Collection schema 中定义的字段匹配
@@ -727,16 +1226,21 @@ func extractTypedValueAsInt64(tv *pb.TypedValue) int64 {
 	}
 }
 
-// buildFilterExpr 构建过滤表达式
-func (m *MilvusClient) buildFilterExpr(filter map[string]*pb.TypedValue, filterExpr string) string {
-	// 优先使用复杂表达式
+// buildFilterExpr 构建过滤表达式，三种来源按优先级取第一个非空的：
+//  1. filterNode：结构化 FilterNode DSL，经 CompileFilterNode 编译成安全转义过的表达式
+//  2. filterExpr：调用方手写的原始表达式，按原样透传，调用方自己负责转义
+//  3. filter：简单等值条件的 AND 链，维持旧行为
+func (m *MilvusClient) buildFilterExpr(filterNode *pb.FilterNode, filter map[string]*pb.TypedValue, filterExpr string) (string, error) {
+	if filterNode != nil {
+		return CompileFilterNode(filterNode)
+	}
+
 	if filterExpr != "" {
-		return filterExpr
+		return filterExpr, nil
 	}
 
-	// 使用简单等值条件构建表达式
 	if len(filter) == 0 {
-		return ""
+		return "", nil
 	}
 
 	conditions := make([]string, 0, len(filter))
@@ -757,7 +1261,7 @@ func (m *MilvusClient) buildFilterExpr(filter map[string]*pb.TypedValue, filterE
 		}
 	}
 
-	return strings.Join(conditions, " && ")
+	return strings.Join(conditions, " && "), nil
 }
 
 // fieldToTypedValue 将 Milvus 字段值转换为 TypedValue
@@ -785,6 +1289,10 @@ func (m *MilvusClient) fieldToTypedValue(field entity.Column, idx int) *pb.Typed
 		// JSON 类型：将 []byte 转换为字符串返回
 		val, _ := col.ValueByIdx(idx)
 		return &pb.TypedValue{Value: &pb.TypedValue_StringValue{StringValue: string(val)}}
+	case *entity.ColumnFloatVector:
+		// 向量字段：通过 "%" 通配符或显式字段名请求时才会出现
+		val, _ := col.ValueByIdx(idx)
+		return &pb.TypedValue{Value: &pb.TypedValue_FloatVectorValue{FloatVectorValue: &pb.FloatVector{Values: val}}}
 	default:
 		// 默认转为字符串
 		return &pb.TypedValue{Value: &pb.TypedValue_StringValue{StringValue: fmt.Sprintf("%v", field)}}
@@ -814,6 +1322,17 @@ func extractTypedValueAsString(tv *pb.TypedValue) string {
 	}
 }
 
+// isSchemaChangeError 粗略判断 Search 报错是否由 schema 变更引起（例如请求的字段已被删除）
+// 用于决定是否需要失效 schema 缓存，而不是严格解析 Milvus 的错误码
+func isSchemaChangeError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "field not exist") ||
+		strings.Contains(msg, "field not found") ||
+		strings.Contains(msg, "schema") ||
+		strings.Contains(msg, "collection not found") ||
+		strings.Contains(msg, "collection not exist")
+}
+
 // quoteStrings 为字符串数组添加引号
 func quoteStrings(strs []string) []string {
 	result := make([]string, len(strs))
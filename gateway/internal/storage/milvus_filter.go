@@ -0,0 +1,186 @@
+// milvus_filter.go
+package storage
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	pb "bot_agent/gateway/internal/pb"
+)
+
+// filterFieldPattern 限定 FilterLeaf.Field 只能是合法标识符，拒绝任何可能带来表达式注入的写法
+// （引号、括号、运算符等），不支持 buildFilterExpr 旧的简单 filter 模式那样把任意 key 拼进表达式
+var filterFieldPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// filterOpSymbol 把比较类 FilterOp 映射为 Milvus 布尔表达式里的运算符
+var filterOpSymbol = map[pb.FilterOp]string{
+	pb.FilterOp_EQ:  "==",
+	pb.FilterOp_NE:  "!=",
+	pb.FilterOp_LT:  "<",
+	pb.FilterOp_LTE: "<=",
+	pb.FilterOp_GT:  ">",
+	pb.FilterOp_GTE: ">=",
+}
+
+// CompileFilterNode 把结构化的 FilterNode DSL 编译成 Milvus 布尔表达式字符串，
+// 递归处理 AND/OR/NOT 复合节点和 EQ/NE/LT/LTE/GT/GTE/IN/NOT_IN/LIKE/JSON_CONTAINS/
+// JSON_CONTAINS_ANY/ARRAY_CONTAINS 叶子节点，所有字符串字面量都会被引号转义，
+// 字段名必须是合法标识符，不是合法标识符的字段名和空的 IN/NOT_IN/JSON_CONTAINS_ANY 列表会被拒绝；
+// 字段名只做格式校验，不检查它是否存在于 collection 的 schema 中
+func CompileFilterNode(node *pb.FilterNode) (string, error) {
+	if node == nil {
+		return "", nil
+	}
+	return compileFilterNode(node)
+}
+
+func compileFilterNode(node *pb.FilterNode) (string, error) {
+	if node == nil {
+		return "", fmt.Errorf("filter node is nil")
+	}
+	switch n := node.GetNode().(type) {
+	case *pb.FilterNode_And:
+		return compileFilterGroup(n.And, " && ")
+	case *pb.FilterNode_Or:
+		return compileFilterGroup(n.Or, " || ")
+	case *pb.FilterNode_Not:
+		inner, err := compileFilterNode(n.Not)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("not (%s)", inner), nil
+	case *pb.FilterNode_Leaf:
+		return compileFilterLeaf(n.Leaf)
+	default:
+		return "", fmt.Errorf("filter node has no and/or/not/leaf set")
+	}
+}
+
+// compileFilterGroup 编译 AND/OR 复合节点，每个子节点的表达式都加括号，避免和外层运算符混在一起
+// 造成优先级歧义
+func compileFilterGroup(group *pb.FilterGroup, sep string) (string, error) {
+	if group == nil || len(group.Nodes) == 0 {
+		return "", fmt.Errorf("filter group requires at least one child node")
+	}
+	parts := make([]string, 0, len(group.Nodes))
+	for _, sub := range group.Nodes {
+		expr, err := compileFilterNode(sub)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, fmt.Sprintf("(%s)", expr))
+	}
+	return strings.Join(parts, sep), nil
+}
+
+// compileFilterLeaf 编译单个叶子条件
+func compileFilterLeaf(leaf *pb.FilterLeaf) (string, error) {
+	if leaf == nil {
+		return "", fmt.Errorf("filter leaf is nil")
+	}
+	if !filterFieldPattern.MatchString(leaf.Field) {
+		return "", fmt.Errorf("unknown filter field %q", leaf.Field)
+	}
+
+	switch leaf.Op {
+	case pb.FilterOp_EQ, pb.FilterOp_NE, pb.FilterOp_LT, pb.FilterOp_LTE, pb.FilterOp_GT, pb.FilterOp_GTE:
+		lit, err := formatFilterLiteral(leaf.Value)
+		if err != nil {
+			return "", fmt.Errorf("filter field %q: %w", leaf.Field, err)
+		}
+		return fmt.Sprintf("%s %s %s", leaf.Field, filterOpSymbol[leaf.Op], lit), nil
+
+	case pb.FilterOp_IN, pb.FilterOp_NOT_IN:
+		if len(leaf.Values) == 0 {
+			return "", fmt.Errorf("filter field %q: IN/NOT_IN requires at least one value", leaf.Field)
+		}
+		lits, err := formatFilterLiterals(leaf.Values)
+		if err != nil {
+			return "", fmt.Errorf("filter field %q: %w", leaf.Field, err)
+		}
+		inExpr := fmt.Sprintf("%s in [%s]", leaf.Field, strings.Join(lits, ", "))
+		if leaf.Op == pb.FilterOp_NOT_IN {
+			return fmt.Sprintf("not (%s)", inExpr), nil
+		}
+		return inExpr, nil
+
+	case pb.FilterOp_LIKE:
+		lit, err := formatFilterLiteral(leaf.Value)
+		if err != nil {
+			return "", fmt.Errorf("filter field %q: %w", leaf.Field, err)
+		}
+		return fmt.Sprintf("%s like %s", leaf.Field, lit), nil
+
+	case pb.FilterOp_JSON_CONTAINS:
+		lit, err := formatFilterLiteral(leaf.Value)
+		if err != nil {
+			return "", fmt.Errorf("filter field %q: %w", leaf.Field, err)
+		}
+		return fmt.Sprintf("json_contains(%s, %s)", leaf.Field, lit), nil
+
+	case pb.FilterOp_JSON_CONTAINS_ANY:
+		if len(leaf.Values) == 0 {
+			return "", fmt.Errorf("filter field %q: JSON_CONTAINS_ANY requires at least one value", leaf.Field)
+		}
+		lits, err := formatFilterLiterals(leaf.Values)
+		if err != nil {
+			return "", fmt.Errorf("filter field %q: %w", leaf.Field, err)
+		}
+		return fmt.Sprintf("json_contains_any(%s, [%s])", leaf.Field, strings.Join(lits, ", ")), nil
+
+	case pb.FilterOp_ARRAY_CONTAINS:
+		lit, err := formatFilterLiteral(leaf.Value)
+		if err != nil {
+			return "", fmt.Errorf("filter field %q: %w", leaf.Field, err)
+		}
+		return fmt.Sprintf("array_contains(%s, %s)", leaf.Field, lit), nil
+
+	default:
+		return "", fmt.Errorf("filter field %q: unsupported operator %v", leaf.Field, leaf.Op)
+	}
+}
+
+// formatFilterLiterals 批量格式化 IN / JSON_CONTAINS_ANY 的值列表
+func formatFilterLiterals(values []*pb.TypedValue) ([]string, error) {
+	lits := make([]string, 0, len(values))
+	for _, v := range values {
+		lit, err := formatFilterLiteral(v)
+		if err != nil {
+			return nil, err
+		}
+		lits = append(lits, lit)
+	}
+	return lits, nil
+}
+
+// formatFilterLiteral 把 TypedValue 格式化为 Milvus 表达式里的字面量：字符串加引号并转义
+// 反斜杠和引号本身，数字/布尔按原生格式输出，不允许空值
+func formatFilterLiteral(tv *pb.TypedValue) (string, error) {
+	if tv == nil {
+		return "", fmt.Errorf("value is required")
+	}
+	switch v := tv.GetValue().(type) {
+	case *pb.TypedValue_StringValue:
+		return quoteFilterString(v.StringValue), nil
+	case *pb.TypedValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10), nil
+	case *pb.TypedValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'g', -1, 64), nil
+	case *pb.TypedValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue), nil
+	case *pb.TypedValue_TimestampValue:
+		return strconv.FormatInt(v.TimestampValue, 10), nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+// quoteFilterString 给字符串字面量加引号，并转义反斜杠和双引号本身，防止拼出的表达式被截断
+// 或注入额外的条件
+func quoteFilterString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
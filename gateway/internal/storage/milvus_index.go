@@ -0,0 +1,188 @@
+// milvus_index.go
+package storage
+
+import (
+	pb "bot_agent/gateway/internal/pb"
+	"context"
+	"strings"
+
+	"bot_agent/gateway/internal/config"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// IndexProfile 描述一个 collection 应使用的索引类型、距离度量以及检索时的可调参数。
+// 取代过去硬编码的 entity.NewIndexIvfFlatSearchParam(16) + entity.L2，
+// 按 请求覆盖 > milvus.index_profiles 配置 > DescribeIndex 自动探测 的优先级解析，详见 resolveIndexProfile
+type IndexProfile struct {
+	MetricType entity.MetricType
+	IndexType  string
+
+	Nprobe         int // IVF_FLAT 系列
+	Ef             int // HNSW
+	SearchListSize int // DISKANN
+}
+
+// merge 用 override 中已设置的字段覆盖 base，返回合并后的 profile；override 为 nil 时直接返回 base
+func (base *IndexProfile) merge(override *IndexProfile) *IndexProfile {
+	if override == nil {
+		return base
+	}
+	merged := *base
+	if override.MetricType != "" {
+		merged.MetricType = override.MetricType
+	}
+	if override.IndexType != "" {
+		merged.IndexType = override.IndexType
+	}
+	if override.Nprobe > 0 {
+		merged.Nprobe = override.Nprobe
+	}
+	if override.Ef > 0 {
+		merged.Ef = override.Ef
+	}
+	if override.SearchListSize > 0 {
+		merged.SearchListSize = override.SearchListSize
+	}
+	return &merged
+}
+
+// defaultIndexProfile 兜底默认值，和历史硬编码行为一致：IVF_FLAT(nprobe=16) + L2
+func defaultIndexProfile() *IndexProfile {
+	return &IndexProfile{
+		MetricType: entity.L2,
+		IndexType:  "IVF_FLAT",
+		Nprobe:     16,
+	}
+}
+
+// searchIndexProfileOverride 把 VectorSearchOp 上的显式覆盖字段转成 IndexProfile；
+// 全部字段都未设置时返回 nil，表示这次请求不覆盖 collection 的默认 profile
+func searchIndexProfileOverride(searchOp *pb.VectorSearchOp) *IndexProfile {
+	override := &IndexProfile{}
+	set := false
+
+	if searchOp.MetricType != "" {
+		override.MetricType = entity.MetricType(strings.ToUpper(searchOp.MetricType))
+		set = true
+	}
+	if searchOp.IndexType != "" {
+		override.IndexType = strings.ToUpper(searchOp.IndexType)
+		set = true
+	}
+	if searchOp.Nprobe > 0 {
+		override.Nprobe = int(searchOp.Nprobe)
+		set = true
+	}
+	if searchOp.Ef > 0 {
+		override.Ef = int(searchOp.Ef)
+		set = true
+	}
+	if searchOp.SearchListSize > 0 {
+		override.SearchListSize = int(searchOp.SearchListSize)
+		set = true
+	}
+
+	if !set {
+		return nil
+	}
+	return override
+}
+
+// resolveIndexProfile 按优先级解析 collection 的索引 profile：
+//  1. override 非 nil 时，其中已设置的字段优先生效（来自请求上的显式覆盖）
+//  2. cfg 中 milvus.index_profiles.<collection> 下配置的静态值
+//  3. 通过 DescribeIndex 自动探测 collection 实际使用的索引，并按 collection 缓存
+func (m *MilvusClient) resolveIndexProfile(ctx context.Context, collection string, override *IndexProfile) (*IndexProfile, error) {
+	if profile := m.configIndexProfile(collection); profile != nil {
+		return profile.merge(override), nil
+	}
+
+	profile, err := m.autoDetectIndexProfile(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	return profile.merge(override), nil
+}
+
+// configIndexProfile 读取 milvus.index_profiles.<collection> 下的静态配置；该 collection 的 metric_type
+// 和 index_type 都未配置时返回 nil（此时只配置的 nprobe/ef/search_list_size 也不生效）
+func (m *MilvusClient) configIndexProfile(collection string) *IndexProfile {
+	if m.cfg == nil {
+		return nil
+	}
+
+	const base = "milvus.index_profiles"
+	metricType := config.GetIn(m.cfg, base, []string{collection, "metric_type"}, "")
+	indexType := config.GetIn(m.cfg, base, []string{collection, "index_type"}, "")
+	if metricType == "" && indexType == "" {
+		return nil
+	}
+
+	return &IndexProfile{
+		MetricType:     entity.MetricType(strings.ToUpper(metricType)),
+		IndexType:      strings.ToUpper(indexType),
+		Nprobe:         config.GetIntIn(m.cfg, base, []string{collection, "nprobe"}, 0),
+		Ef:             config.GetIntIn(m.cfg, base, []string{collection, "ef"}, 0),
+		SearchListSize: config.GetIntIn(m.cfg, base, []string{collection, "search_list_size"}, 0),
+	}
+}
+
+// autoDetectIndexProfile 通过 DescribeIndex 探测 collection 实际使用的索引类型和度量方式，
+// 结果按 collection 缓存，避免每次搜索都发一次 RPC；探测失败时退回 defaultIndexProfile，不阻塞搜索
+func (m *MilvusClient) autoDetectIndexProfile(ctx context.Context, collection string) (*IndexProfile, error) {
+	m.indexProfileMu.RLock()
+	cached, ok := m.indexProfileCache[collection]
+	m.indexProfileMu.RUnlock()
+	if ok {
+		return cached, nil
+	}
+
+	indexes, err := m.client.DescribeIndex(ctx, collection, "")
+	if err != nil || len(indexes) == 0 {
+		return defaultIndexProfile(), nil
+	}
+
+	params := indexes[0].Params()
+	profile := &IndexProfile{
+		MetricType: entity.MetricType(params["metric_type"]),
+		IndexType:  strings.ToUpper(params["index_type"]),
+		Nprobe:     16,
+	}
+	if profile.MetricType == "" {
+		profile.MetricType = entity.L2
+	}
+	if profile.IndexType == "" {
+		profile.IndexType = "IVF_FLAT"
+	}
+
+	m.indexProfileMu.Lock()
+	m.indexProfileCache[collection] = profile
+	m.indexProfileMu.Unlock()
+
+	return profile, nil
+}
+
+// buildSearchParam 按 IndexProfile.IndexType 构建对应的 entity.SearchParam
+func buildSearchParam(profile *IndexProfile) (entity.SearchParam, error) {
+	switch strings.ToUpper(profile.IndexType) {
+	case "HNSW":
+		ef := profile.Ef
+		if ef <= 0 {
+			ef = 64
+		}
+		return entity.NewIndexHNSWSearchParam(ef)
+	case "DISKANN":
+		searchListSize := profile.SearchListSize
+		if searchListSize <= 0 {
+			searchListSize = 100
+		}
+		return entity.NewIndexDiskANNSearchParam(searchListSize)
+	default: // IVF_FLAT 及其变体（IVF_SQ8、IVF_PQ 等共用同一套检索参数）
+		nprobe := profile.Nprobe
+		if nprobe <= 0 {
+			nprobe = 16
+		}
+		return entity.NewIndexIvfFlatSearchParam(nprobe)
+	}
+}
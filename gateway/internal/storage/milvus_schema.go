@@ -0,0 +1,265 @@
+// milvus_schema.go
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"bot_agent/gateway/internal/config"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// CollectionSpec 声明式描述一个 Milvus Collection 的 schema，在 Collection 不存在时用来自动创建
+// 零值字段在 setDefaults 里会被填充为合理的默认值，调用方只需要填自己关心的部分
+type CollectionSpec struct {
+	PKField     string           // 主键字段名，默认 "id"
+	PKType      entity.FieldType // 主键类型，仅支持 VarChar / Int64，默认 VarChar
+	PKMaxLength int              // 主键为 VarChar 时的最大长度，默认 64
+
+	VectorField string // 向量字段名，默认 "vector"
+	Dimension   int    // 向量维度，不指定则使用 NewMilvusClient 传入的 dimension
+
+	MetricType  entity.MetricType // 距离度量方式：L2 / IP / COSINE，默认 L2
+	IndexType   string            // 索引类型：IVF_FLAT / HNSW / DISKANN，默认 IVF_FLAT
+	IndexParams map[string]int    // 索引参数，如 IVF_FLAT 的 nlist、HNSW 的 M/efConstruction
+
+	ScalarFields []ScalarFieldSpec // 动态标量字段
+	ShardNum     int32             // Collection 的 shard 数，默认 2
+}
+
+// ScalarFieldSpec 描述一个动态标量字段，支持 JSON 和 Array 字段
+type ScalarFieldSpec struct {
+	Name        string
+	Type        entity.FieldType // Int64/Double/Bool/VarChar/JSON/Array 等
+	MaxLength   int              // Type 为 VarChar 时的最大长度，默认 256
+	ElementType entity.FieldType // Type 为 Array 时，数组元素类型
+	MaxCapacity int              // Type 为 Array 时，最大元素个数，默认 100
+}
+
+// setDefaults 填充未配置的字段；fallbackDimension 来自 MilvusClient 构造时传入的 dimension
+//
+// PKField/VectorField 目前只有这里和 createCollectionFromSpec 认识：milvus.go 里 buildColumns（插入/更新）
+// 和 executeSearch 的单向量路径都还是硬编码读写 "id"/"vector" 字段，没有按 spec 转发。在那几处打通之前，
+// 非默认的 PKField/VectorField 会导致 Collection 建对了，但随后每次插入/搜索都报字段不存在，所以这里先
+// 拒绝非默认值，而不是假装支持
+func (s *CollectionSpec) setDefaults(fallbackDimension int) error {
+	if s.PKField == "" {
+		s.PKField = "id"
+	} else if s.PKField != "id" {
+		return fmt.Errorf("collection_spec.pk_field %q is not supported yet: milvus.go insert/search paths are hardcoded to \"id\"", s.PKField)
+	}
+	if s.PKType == 0 {
+		s.PKType = entity.FieldTypeVarChar
+	}
+	if s.PKMaxLength <= 0 {
+		s.PKMaxLength = 64
+	}
+	if s.VectorField == "" {
+		s.VectorField = "vector"
+	} else if s.VectorField != "vector" {
+		return fmt.Errorf("collection_spec.vector_field %q is not supported yet: milvus.go insert/search paths are hardcoded to \"vector\"", s.VectorField)
+	}
+	if s.Dimension <= 0 {
+		s.Dimension = fallbackDimension
+	}
+	if s.MetricType == "" {
+		s.MetricType = entity.L2
+	}
+	if s.IndexType == "" {
+		s.IndexType = "IVF_FLAT"
+	}
+	if s.ShardNum <= 0 {
+		s.ShardNum = 2
+	}
+	return nil
+}
+
+// createCollectionFromSpec 按 CollectionSpec 创建 Collection：建 schema、建索引、加载到内存
+func (m *MilvusClient) createCollectionFromSpec(ctx context.Context, name string, spec *CollectionSpec) error {
+	if err := spec.setDefaults(m.dimension); err != nil {
+		return err
+	}
+
+	schema := entity.NewSchema().WithName(name).WithDescription("auto-created by bot_agent gateway")
+
+	pkField := entity.NewField().WithName(spec.PKField).WithDataType(spec.PKType).WithIsPrimaryKey(true)
+	if spec.PKType == entity.FieldTypeVarChar {
+		pkField = pkField.WithMaxLength(int64(spec.PKMaxLength))
+	}
+	schema.WithField(pkField)
+
+	schema.WithField(entity.NewField().
+		WithName(spec.VectorField).
+		WithDataType(entity.FieldTypeFloatVector).
+		WithDim(int64(spec.Dimension)))
+
+	for _, f := range spec.ScalarFields {
+		field := entity.NewField().WithName(f.Name).WithDataType(f.Type)
+		switch f.Type {
+		case entity.FieldTypeVarChar:
+			maxLength := f.MaxLength
+			if maxLength <= 0 {
+				maxLength = 256
+			}
+			field = field.WithMaxLength(int64(maxLength))
+		case entity.FieldTypeArray:
+			maxCapacity := f.MaxCapacity
+			if maxCapacity <= 0 {
+				maxCapacity = 100
+			}
+			field = field.WithElementType(f.ElementType).WithMaxCapacity(int64(maxCapacity))
+		}
+		schema.WithField(field)
+	}
+
+	if err := m.client.CreateCollection(ctx, schema, spec.ShardNum); err != nil {
+		return fmt.Errorf("create collection failed: %w", err)
+	}
+
+	idx, err := buildIndex(spec)
+	if err != nil {
+		return fmt.Errorf("build index failed: %w", err)
+	}
+	if err := m.client.CreateIndex(ctx, name, spec.VectorField, idx, false); err != nil {
+		return fmt.Errorf("create index failed: %w", err)
+	}
+
+	if err := m.client.LoadCollection(ctx, name, false); err != nil {
+		return fmt.Errorf("load collection failed: %w", err)
+	}
+
+	return nil
+}
+
+// buildIndex 按 IndexType 构建向量索引，参数从 IndexParams 读取，未配置则使用常见默认值
+func buildIndex(spec *CollectionSpec) (entity.Index, error) {
+	switch strings.ToUpper(spec.IndexType) {
+	case "HNSW":
+		m := intParam(spec.IndexParams, "M", 16)
+		efConstruction := intParam(spec.IndexParams, "efConstruction", 64)
+		return entity.NewIndexHNSW(spec.MetricType, m, efConstruction)
+	case "DISKANN":
+		return entity.NewIndexDISKANN(spec.MetricType)
+	default: // IVF_FLAT
+		nlist := intParam(spec.IndexParams, "nlist", 128)
+		return entity.NewIndexIvfFlat(spec.MetricType, nlist)
+	}
+}
+
+func intParam(params map[string]int, key string, defaultVal int) int {
+	if v, ok := params[key]; ok && v > 0 {
+		return v
+	}
+	return defaultVal
+}
+
+// LoadCollectionSpecFromConfig 从通用配置 map 中解析 milvus.collection_spec，未配置时返回 nil（维持旧行为）
+// YAML 形如：
+//
+//	milvus:
+//	  collection_spec:
+//	    pk_field: id
+//	    pk_type: varchar
+//	    vector_field: vector
+//	    metric_type: COSINE
+//	    index_type: HNSW
+//	    index_params:
+//	      M: 16
+//	      efConstruction: 64
+//	    scalar_fields:
+//	      - name: deployment_id
+//	        type: varchar
+//	        max_length: 128
+//	      - name: metadata
+//	        type: json
+func LoadCollectionSpecFromConfig(cfg map[string]interface{}) (*CollectionSpec, error) {
+	raw := config.GetRaw(cfg, "milvus.collection_spec")
+	if raw == nil {
+		return nil, nil
+	}
+
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("milvus.collection_spec must be an object")
+	}
+
+	spec := &CollectionSpec{
+		PKField:     stringField(m, "pk_field"),
+		PKType:      fieldTypeFromString(stringField(m, "pk_type")),
+		PKMaxLength: intField(m, "pk_max_length"),
+		VectorField: stringField(m, "vector_field"),
+		Dimension:   intField(m, "dimension"),
+		MetricType:  entity.MetricType(stringField(m, "metric_type")),
+		IndexType:   stringField(m, "index_type"),
+		ShardNum:    int32(intField(m, "shard_num")),
+	}
+
+	if rawParams, ok := m["index_params"].(map[string]interface{}); ok {
+		spec.IndexParams = make(map[string]int, len(rawParams))
+		for k := range rawParams {
+			spec.IndexParams[k] = intField(rawParams, k)
+		}
+	}
+
+	if rawFields, ok := m["scalar_fields"].([]interface{}); ok {
+		for i, item := range rawFields {
+			fm, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("milvus.collection_spec.scalar_fields[%d] must be an object", i)
+			}
+			spec.ScalarFields = append(spec.ScalarFields, ScalarFieldSpec{
+				Name:        stringField(fm, "name"),
+				Type:        fieldTypeFromString(stringField(fm, "type")),
+				MaxLength:   intField(fm, "max_length"),
+				ElementType: fieldTypeFromString(stringField(fm, "element_type")),
+				MaxCapacity: intField(fm, "max_capacity"),
+			})
+		}
+	}
+
+	return spec, nil
+}
+
+// fieldTypeFromString 把配置里的字符串类型名映射为 entity.FieldType，默认按 VarChar 处理
+func fieldTypeFromString(s string) entity.FieldType {
+	switch strings.ToUpper(s) {
+	case "INT64":
+		return entity.FieldTypeInt64
+	case "DOUBLE":
+		return entity.FieldTypeDouble
+	case "FLOAT":
+		return entity.FieldTypeFloat
+	case "BOOL":
+		return entity.FieldTypeBool
+	case "JSON":
+		return entity.FieldTypeJSON
+	case "ARRAY":
+		return entity.FieldTypeArray
+	default:
+		return entity.FieldTypeVarChar
+	}
+}
+
+// stringField 从配置子对象中读取字符串字段
+func stringField(m map[string]interface{}, key string) string {
+	if v, ok := m[key]; ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// intField 从配置子对象中读取整数字段；YAML 解析出来的数字常见为 int，环境覆盖场景也可能是 float64
+func intField(m map[string]interface{}, key string) int {
+	switch v := m[key].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -15,29 +17,67 @@ import (
 // 对于调用链中的某个批量操作，默认是对相同列进行批量插入，如果涉及到不同列，应该于调用链上再追加一个批量操作
 type MySQLClient struct {
     db *sql.DB
-} 
+    allowRawSQL bool // 是否允许 RawSet/RawClause 透传原始 SQL 片段，见 MySQLClientOptions.AllowRawSQL
+
+    opStatsMu sync.RWMutex
+    opStats   map[string]*opLatencyStats // 操作类型("insert"/"update"/"delete"/"select") -> 耗时统计，见 Stats()
+
+    stmtCache *stmtCache // 预编译语句缓存，nil 表示未开启（见 MySQLClientOptions.StmtCacheSize）
+}
 
 // 辅助接口, 使得事务和非事务执行可以共用同一套方法
 type executor interface {
     ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
     QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
-} 
+}
 
-func NewMySQLClient(dsn string) (*MySQLClient, error) {
-	db, err := sql.Open("mysql", dsn)
-	if err != nil {
-		return nil, fmt.Errorf("sql open error: %w", err)
+// execCached 在 cacheable 且缓存开启时复用/准备好的 *sql.Stmt 上执行 ExecContext，否则直接走 exec
+func (c *MySQLClient) execCached(ctx context.Context, exec executor, cacheable bool, query string, args ...interface{}) (sql.Result, error) {
+	if cacheable && c.stmtCache != nil {
+		stmt, err := c.stmtCache.getOrPrepare(ctx, c.db, query)
+		if err != nil {
+			return nil, err
+		}
+		return stmt.ExecContext(ctx, args...)
 	}
-	
-	
-	if err := db.Ping(); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("sql ping error: %w", err)
+	return exec.ExecContext(ctx, query, args...)
+}
+
+// queryCached 和 execCached 同理，对应 QueryContext
+func (c *MySQLClient) queryCached(ctx context.Context, exec executor, cacheable bool, query string, args ...interface{}) (*sql.Rows, error) {
+	if cacheable && c.stmtCache != nil {
+		stmt, err := c.stmtCache.getOrPrepare(ctx, c.db, query)
+		if err != nil {
+			return nil, err
+		}
+		return stmt.QueryContext(ctx, args...)
 	}
-	return &MySQLClient{db: db}, nil
+	return exec.QueryContext(ctx, query, args...)
+}
+
+// MySQLClientOptions 是 NewMySQLClient 的可选配置，零值即为默认行为
+type MySQLClientOptions struct {
+	// AllowRawSQL 控制是否允许调用方通过 RawSet（UPDATE）、RawClause（WHERE/HAVING）透传原始 SQL
+	// 片段。默认 false：不受信任的上游（如直接转发外部请求的网关）拿到的 pb.Operation 不能借此
+	// 拼出任意 SQL，只能用 SetFields/Conditions/Node 这些结构化字段
+	AllowRawSQL bool
+
+	// StmtCacheSize 是预编译语句 LRU 缓存的容量，<=0 表示不开启缓存（默认行为，和旧版本一致）
+	StmtCacheSize int
+	// StmtCacheTTL 是缓存项的存活时间，<=0 表示永不过期（仅受 StmtCacheSize 的 LRU 淘汰约束）
+	StmtCacheTTL time.Duration
+}
+
+// NewMySQLClient 是 NewMySQLClientWithConfig 的简化入口，只接受 DSN，连接池和 Ping 重试都用默认值，
+// 和旧版本行为一致：Ping 一次，失败直接返回错误
+func NewMySQLClient(dsn string, opts *MySQLClientOptions) (*MySQLClient, error) {
+	return NewMySQLClientWithConfig(MySQLConfig{DSN: dsn}, opts)
 }
 
 func (c *MySQLClient) Close() error {
+	if c.stmtCache != nil {
+		c.stmtCache.close()
+	}
 	return c.db.Close()
 }
 
@@ -71,20 +111,27 @@ func (c *MySQLClient) ExecuteBatch(ctx context.Context, ops []*pb.Operation, use
 
 	for i, op := range ops {
 		var res *pb.OperationResult
+		var opName string
+		start := time.Now()
 
 		// 使用 oneof 类型断言判断操作类型
 		switch op.GetOperation().(type) {
 		case *pb.Operation_Insert:
+			opName = "insert"
 			res = c.executeInsert(ctx, exec, op, i)
 		case *pb.Operation_Update:
+			opName = "update"
 			res = c.executeUpdate(ctx, exec, op, i)
 		case *pb.Operation_Delete:
+			opName = "delete"
 			res = c.executeDelete(ctx, exec, op, i)
 		case *pb.Operation_Select:
+			opName = "select"
 			res = c.executeSelect(ctx, exec, op, i)
 		default:
 			return nil, fmt.Errorf("unknown operation type")
 		}
+		c.recordOpLatency(opName, time.Since(start))
 
 		if !res.Success && useTx {
 			// 返回错误，defer 会自动执行 Rollback
@@ -141,11 +188,30 @@ func (c *MySQLClient) executeInsert(ctx context.Context, exec executor, op *pb.O
 		}
 	}
 
-	// 提取列名并保证顺序一致
+	// 提取列名并排序，保证顺序一致（map 遍历顺序不固定），同时让列集合和行数都相同的批次渲染出同一个模板，
+	// 命中 Stmt 缓存
 	columns := make([]string, 0, len(firstRow.Fields))
 	for col := range firstRow.Fields {
 		columns = append(columns, col)
 	}
+	sort.Strings(columns)
+
+	qualifiedTable, err := quoteQualifiedIdent(database, table)
+	if err != nil {
+		return &pb.OperationResult{
+			Index:   int32(index),
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+	quotedColumns, err := quoteIdents(columns)
+	if err != nil {
+		return &pb.OperationResult{
+			Index:   int32(index),
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
 
 	// 构建批量 VALUES 占位符和参数
 	rowPlaceholders := make([]string, len(insertOp.Rows))
@@ -160,14 +226,30 @@ func (c *MySQLClient) executeInsert(ctx context.Context, exec executor, op *pb.O
 		}
 	}
 
-	// 构建批量 INSERT 语句: INSERT INTO db.table (a, b) VALUES (?, ?), (?, ?)
-	query := fmt.Sprintf("INSERT INTO %s.%s (%s) VALUES %s",
-		database,
-		table,
-		strings.Join(columns, ", "),
+	// 构建批量 INSERT 语句: INSERT INTO `db`.`table` (`a`, `b`) VALUES (?, ?), (?, ?)
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s",
+		qualifiedTable,
+		strings.Join(quotedColumns, ", "),
 		strings.Join(rowPlaceholders, ", "))
 
-	result, err := exec.ExecContext(ctx, query, values...)
+	// upsert_update_columns 非空时追加 ON DUPLICATE KEY UPDATE，用 VALUES(col) 引用本次插入的新值
+	if len(insertOp.UpsertUpdateColumns) > 0 {
+		quotedUpdateColumns, err := quoteIdents(insertOp.UpsertUpdateColumns)
+		if err != nil {
+			return &pb.OperationResult{
+				Index:   int32(index),
+				Success: false,
+				Error:   err.Error(),
+			}
+		}
+		updateClauses := make([]string, len(quotedUpdateColumns))
+		for i, col := range quotedUpdateColumns {
+			updateClauses[i] = fmt.Sprintf("%s = VALUES(%s)", col, col)
+		}
+		query += " ON DUPLICATE KEY UPDATE " + strings.Join(updateClauses, ", ")
+	}
+
+	result, err := c.execCached(ctx, exec, isCacheable(exec), query, values...)
 	if err != nil {
 		return &pb.OperationResult{
 			Index:   int32(index),
@@ -220,8 +302,15 @@ func (c *MySQLClient) executeUpdate(ctx context.Context, exec executor, op *pb.O
 	var setClause string
 	var values []interface{}
 
-	// 优先使用 raw_set（支持 SQL 表达式，如 access_count = access_count + 1）
+	// 优先使用 raw_set（支持 SQL 表达式，如 access_count = access_count + 1），仅在 AllowRawSQL 开启时允许
 	if updateOp.RawSet != "" {
+		if !c.allowRawSQL {
+			return &pb.OperationResult{
+				Index:   int32(index),
+				Success: false,
+				Error:   "raw_set is disabled (set MySQLClientOptions.AllowRawSQL to enable)",
+			}
+		}
 		setClause = updateOp.RawSet
 		for _, p := range updateOp.RawSetParams {
 			values = append(values, extractTypedValue(p))
@@ -230,7 +319,15 @@ func (c *MySQLClient) executeUpdate(ctx context.Context, exec executor, op *pb.O
 		// 使用简单字段赋值
 		setClauses := make([]string, 0, len(updateOp.SetFields))
 		for col, typedVal := range updateOp.SetFields {
-			setClauses = append(setClauses, fmt.Sprintf("%s = ?", col))
+			quotedCol, err := quoteIdent(col)
+			if err != nil {
+				return &pb.OperationResult{
+					Index:   int32(index),
+					Success: false,
+					Error:   err.Error(),
+				}
+			}
+			setClauses = append(setClauses, fmt.Sprintf("%s = ?", quotedCol))
 			values = append(values, extractTypedValue(typedVal))
 		}
 		setClause = strings.Join(setClauses, ", ")
@@ -242,17 +339,34 @@ func (c *MySQLClient) executeUpdate(ctx context.Context, exec executor, op *pb.O
 		}
 	}
 
+	qualifiedTable, err := quoteQualifiedIdent(database, table)
+	if err != nil {
+		return &pb.OperationResult{
+			Index:   int32(index),
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
 	// 构建 WHERE 子句
-	whereClause, whereParams := buildWhereClause(updateOp.Where)
+	whereClause, whereParams, err := c.buildWhereClause(updateOp.Where)
+	if err != nil {
+		return &pb.OperationResult{
+			Index:   int32(index),
+			Success: false,
+			Error:   fmt.Sprintf("build where clause error: %v", err),
+		}
+	}
 	values = append(values, whereParams...)
 
-	query := fmt.Sprintf("UPDATE %s.%s SET %s%s",
-		database,
-		table,
+	query := fmt.Sprintf("UPDATE %s SET %s%s",
+		qualifiedTable,
 		setClause,
 		whereClause)
 
-	result, err := exec.ExecContext(ctx, query, values...)
+	// raw_set/raw_clause 每次拼出的片段形状不固定，不能假设相同 SQL 文本会重复出现，跳过缓存
+	cacheable := isCacheable(exec) && updateOp.RawSet == "" && !usesRawClause(updateOp.Where)
+	result, err := c.execCached(ctx, exec, cacheable, query, values...)
 	if err != nil {
 		return &pb.OperationResult{
 			Index:   int32(index),
@@ -302,7 +416,14 @@ func (c *MySQLClient) executeDelete(ctx context.Context, exec executor, op *pb.O
 	}
 
 	// 构建 WHERE 子句
-	whereClause, whereParams := buildWhereClause(deleteOp.Where)
+	whereClause, whereParams, err := c.buildWhereClause(deleteOp.Where)
+	if err != nil {
+		return &pb.OperationResult{
+			Index:   int32(index),
+			Success: false,
+			Error:   fmt.Sprintf("build where clause error: %v", err),
+		}
+	}
 	if whereClause == "" {
 		return &pb.OperationResult{
 			Index:   int32(index),
@@ -311,9 +432,19 @@ func (c *MySQLClient) executeDelete(ctx context.Context, exec executor, op *pb.O
 		}
 	}
 
-	query := fmt.Sprintf("DELETE FROM %s.%s%s", database, table, whereClause)
+	qualifiedTable, err := quoteQualifiedIdent(database, table)
+	if err != nil {
+		return &pb.OperationResult{
+			Index:   int32(index),
+			Success: false,
+			Error:   err.Error(),
+		}
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s%s", qualifiedTable, whereClause)
 
-	result, err := exec.ExecContext(ctx, query, whereParams...)
+	cacheable := isCacheable(exec) && !usesRawClause(deleteOp.Where)
+	result, err := c.execCached(ctx, exec, cacheable, query, whereParams...)
 	if err != nil {
 		return &pb.OperationResult{
 			Index:   int32(index),
@@ -362,38 +493,17 @@ func (c *MySQLClient) executeSelect(ctx context.Context, exec executor, op *pb.O
 		}
 	}
 
-	// 构建 SELECT 字段
-	fields := "*"
-	if len(selectOp.Fields) > 0 {
-		fields = strings.Join(selectOp.Fields, ", ")
-	}
-
-	// 构建 WHERE 子句
-	whereClause, whereParams := buildWhereClause(selectOp.Where)
-
-	// 构建 ORDER BY
-	orderClause := ""
-	if selectOp.OrderBy != nil && selectOp.OrderBy.Field != "" {
-		direction := "ASC"
-		if selectOp.OrderBy.Descending {
-			direction = "DESC"
-		}
-		orderClause = fmt.Sprintf(" ORDER BY %s %s", selectOp.OrderBy.Field, direction)
-	}
-
-	// 构建 LIMIT/OFFSET
-	limitClause := ""
-	if selectOp.Pagination != nil && selectOp.Pagination.Limit > 0 {
-		limitClause = fmt.Sprintf(" LIMIT %d", selectOp.Pagination.Limit)
-		if selectOp.Pagination.Offset > 0 {
-			limitClause += fmt.Sprintf(" OFFSET %d", selectOp.Pagination.Offset)
+	query, queryParams, err := c.buildSelectQuery(database, table, selectOp)
+	if err != nil {
+		return &pb.OperationResult{
+			Index:   int32(index),
+			Success: false,
+			Error:   err.Error(),
 		}
 	}
 
-	query := fmt.Sprintf("SELECT %s FROM %s.%s%s%s%s",
-		fields, database, table, whereClause, orderClause, limitClause)
-
-	rows, err := exec.QueryContext(ctx, query, whereParams...)
+	cacheable := isCacheable(exec) && !usesRawClause(selectOp.Where) && !usesRawClause(selectOp.Having)
+	rows, err := c.queryCached(ctx, exec, cacheable, query, queryParams...)
 	if err != nil {
 		return &pb.OperationResult{
 			Index:   int32(index),
@@ -566,32 +676,174 @@ func extractTypedValue(tv *pb.TypedValue) interface{} {
 	}
 }
 
-// 辅助构建WHERE
-func buildWhereClause(where *pb.WhereClause) (string, []interface{}) {
+// buildSelectQuery 根据 SelectOperation 拼出完整的 SELECT 语句和参数，executeSelect 和 SelectStream
+// 共用这份逻辑，保证普通查询和流式查询看到的是同一套 WHERE/GROUP BY/ORDER BY/分页规则
+func (c *MySQLClient) buildSelectQuery(database, table string, selectOp *pb.SelectOperation) (string, []interface{}, error) {
+	// 构建 SELECT 字段
+	fields := "*"
+	if len(selectOp.Fields) > 0 {
+		quotedFields, err := quoteIdents(selectOp.Fields)
+		if err != nil {
+			return "", nil, err
+		}
+		fields = strings.Join(quotedFields, ", ")
+	}
+
+	qualifiedTable, err := quoteQualifiedIdent(database, table)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// 构建 WHERE 子句
+	whereClause, whereParams, err := c.buildWhereClause(selectOp.Where)
+	if err != nil {
+		return "", nil, fmt.Errorf("build where clause error: %w", err)
+	}
+
+	// keyset 分页：追加 field > ?（OrderBy.Descending 时是 <），和 Where 之间用 AND 拼接，
+	// 避免大偏移量 OFFSET 扫描，调用方负责保证 OrderBy 和 Keyset.AfterField 一致
+	if selectOp.Keyset != nil {
+		whereClause, whereParams, err = appendKeysetPredicate(whereClause, whereParams, selectOp.Keyset, selectOp.OrderBy)
+		if err != nil {
+			return "", nil, fmt.Errorf("build keyset predicate error: %w", err)
+		}
+	}
+
+	// 构建 GROUP BY
+	groupClause := ""
+	if len(selectOp.GroupBy) > 0 {
+		quotedGroupBy, err := quoteIdents(selectOp.GroupBy)
+		if err != nil {
+			return "", nil, err
+		}
+		groupClause = fmt.Sprintf(" GROUP BY %s", strings.Join(quotedGroupBy, ", "))
+	}
+
+	// 构建 HAVING（分组后再过滤，语义上依赖 GROUP BY，这里不强制校验，交给 MySQL 自己报错）
+	havingClause, havingParams, err := c.buildHavingClause(selectOp.Having)
+	if err != nil {
+		return "", nil, fmt.Errorf("build having clause error: %w", err)
+	}
+
+	// 构建 ORDER BY
+	orderClause := ""
+	if selectOp.OrderBy != nil && selectOp.OrderBy.Field != "" {
+		quotedOrderField, err := quoteIdent(selectOp.OrderBy.Field)
+		if err != nil {
+			return "", nil, err
+		}
+		direction := "ASC"
+		if selectOp.OrderBy.Descending {
+			direction = "DESC"
+		}
+		orderClause = fmt.Sprintf(" ORDER BY %s %s", quotedOrderField, direction)
+	}
+
+	// 构建 LIMIT/OFFSET
+	limitClause := ""
+	if selectOp.Pagination != nil && selectOp.Pagination.Limit > 0 {
+		limitClause = fmt.Sprintf(" LIMIT %d", selectOp.Pagination.Limit)
+		if selectOp.Pagination.Offset > 0 {
+			limitClause += fmt.Sprintf(" OFFSET %d", selectOp.Pagination.Offset)
+		}
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s%s%s%s%s%s",
+		fields, qualifiedTable, whereClause, groupClause, havingClause, orderClause, limitClause)
+
+	queryParams := append(append([]interface{}{}, whereParams...), havingParams...)
+	return query, queryParams, nil
+}
+
+// appendKeysetPredicate 把 KeysetPagination 编译成一个 "field > ?"/"field < ?" 谓词并拼进已有的 WHERE 子句，
+// whereClause 为空时直接生成 " WHERE ..."，否则用 AND 追加在已有条件之后
+func appendKeysetPredicate(whereClause string, whereParams []interface{}, keyset *pb.KeysetPagination, orderBy *pb.OrderBy) (string, []interface{}, error) {
+	if keyset.AfterField == "" {
+		return "", nil, fmt.Errorf("keyset pagination after_field is required")
+	}
+	field, err := quoteIdent(keyset.AfterField)
+	if err != nil {
+		return "", nil, err
+	}
+
+	op := ">"
+	if orderBy != nil && orderBy.Descending {
+		op = "<"
+	}
+	predicate := fmt.Sprintf("%s %s ?", field, op)
+	whereParams = append(whereParams, extractTypedValue(keyset.AfterValue))
+
+	if whereClause == "" {
+		return " WHERE " + predicate, whereParams, nil
+	}
+	return whereClause + " AND " + predicate, whereParams, nil
+}
+
+// buildWhereClause 给 buildConditionClause 的结果加上 " WHERE " 前缀，供 UPDATE/DELETE/SELECT 使用
+func (c *MySQLClient) buildWhereClause(where *pb.WhereClause) (string, []interface{}, error) {
+	expr, params, err := c.buildConditionClause(where)
+	if err != nil {
+		return "", nil, err
+	}
+	if expr == "" {
+		return "", nil, nil
+	}
+	return " WHERE " + expr, params, nil
+}
+
+// buildHavingClause 给 buildConditionClause 的结果加上 " HAVING " 前缀，供 SELECT 的分组后过滤使用，
+// 和 WHERE 共用同一套 WhereClause 结构（Node/RawClause/Conditions）
+func (c *MySQLClient) buildHavingClause(having *pb.WhereClause) (string, []interface{}, error) {
+	expr, params, err := c.buildConditionClause(having)
+	if err != nil {
+		return "", nil, err
+	}
+	if expr == "" {
+		return "", nil, nil
+	}
+	return " HAVING " + expr, params, nil
+}
+
+// buildConditionClause 构建不带前缀的条件表达式，三种来源按优先级取第一个非空的：
+//  1. Node：结构化 WhereNode DSL（AND/OR/NOT + 富操作符），经 compileWhereNode 编译，字段名全部校验转义
+//  2. RawClause：调用方手写的复杂条件，按原样透传，参数走 RawParams，受 allowRawSQL 开关保护
+//  3. Conditions：简单等值条件的 AND 链，维持旧行为，列名经 quoteIdent 校验转义
+func (c *MySQLClient) buildConditionClause(where *pb.WhereClause) (string, []interface{}, error) {
 	if where == nil {
-		return "", nil
+		return "", nil, nil
+	}
+
+	if where.Node != nil {
+		return compileWhereNode(where.Node)
 	}
 
 	var params []interface{}
 
 	// 优先使用 raw_clause（复杂条件）
 	if where.RawClause != "" {
+		if !c.allowRawSQL {
+			return "", nil, fmt.Errorf("raw_clause is disabled (set MySQLClientOptions.AllowRawSQL to enable)")
+		}
 		for _, p := range where.RawParams {
 			params = append(params, extractTypedValue(p))
 		}
-		return " WHERE " + where.RawClause, params
+		return where.RawClause, params, nil
 	}
 
 	// 使用简单等值条件
 	if len(where.Conditions) == 0 {
-		return "", nil
+		return "", nil, nil
 	}
 
 	clauses := make([]string, 0, len(where.Conditions))
 	for col, typedVal := range where.Conditions {
-		clauses = append(clauses, fmt.Sprintf("%s = ?", col))
+		quotedCol, err := quoteIdent(col)
+		if err != nil {
+			return "", nil, err
+		}
+		clauses = append(clauses, fmt.Sprintf("%s = ?", quotedCol))
 		params = append(params, extractTypedValue(typedVal))
 	}
 
-	return " WHERE " + strings.Join(clauses, " AND "), params
+	return strings.Join(clauses, " AND "), params, nil
 }
\ No newline at end of file
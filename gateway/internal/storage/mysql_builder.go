@@ -0,0 +1,200 @@
+// mysql_builder.go
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	pb "bot_agent/gateway/internal/pb"
+)
+
+// QueryBuilder 是 MySQLClient 之上的链式查询构建器：Table(db, tbl).Where(...).OrderBy(...).Limit(...)
+// 这些方法只是攒参数，最终在 Select/Insert/Update/Delete 这些终结方法里拼成 pb.Operation，
+// 交给和 ExecuteBatch 完全相同的 executeXxx 执行，所以 Go 内调用和 gRPC 调用两条路径行为一致
+type QueryBuilder struct {
+	client   *MySQLClient
+	database string
+	table    string
+
+	where   *pb.WhereClause
+	groupBy []string
+	having  *pb.WhereClause
+	orderBy *pb.OrderBy
+	limit   int32
+	offset  int32
+}
+
+// Table 创建一个指向 database.table 的 QueryBuilder
+func (c *MySQLClient) Table(database, table string) *QueryBuilder {
+	return &QueryBuilder{client: c, database: database, table: table}
+}
+
+// Where 用结构化 WhereNode DSL（AND/OR/NOT + 富操作符）设置过滤条件
+func (b *QueryBuilder) Where(node *pb.WhereNode) *QueryBuilder {
+	b.where = &pb.WhereClause{Node: node}
+	return b
+}
+
+// WhereRaw 是逃生舱：直接传手写的条件表达式和对应参数
+func (b *QueryBuilder) WhereRaw(clause string, params ...*pb.TypedValue) *QueryBuilder {
+	b.where = &pb.WhereClause{RawClause: clause, RawParams: params}
+	return b
+}
+
+// Group 设置 GROUP BY 字段，多次调用会追加而不是覆盖
+func (b *QueryBuilder) Group(fields ...string) *QueryBuilder {
+	b.groupBy = append(b.groupBy, fields...)
+	return b
+}
+
+// Having 用结构化 WhereNode DSL 设置分组后的过滤条件
+func (b *QueryBuilder) Having(node *pb.WhereNode) *QueryBuilder {
+	b.having = &pb.WhereClause{Node: node}
+	return b
+}
+
+// OrderBy 设置排序字段和方向
+func (b *QueryBuilder) OrderBy(field string, descending bool) *QueryBuilder {
+	b.orderBy = &pb.OrderBy{Field: field, Descending: descending}
+	return b
+}
+
+// Limit 设置 LIMIT/OFFSET，offset <= 0 时不追加 OFFSET
+func (b *QueryBuilder) Limit(limit, offset int32) *QueryBuilder {
+	b.limit = limit
+	b.offset = offset
+	return b
+}
+
+// Select 执行 SELECT，cols 为空时查询所有列
+func (b *QueryBuilder) Select(ctx context.Context, cols ...string) (*pb.OperationResult, error) {
+	var pagination *pb.Pagination
+	if b.limit > 0 {
+		pagination = &pb.Pagination{Limit: b.limit, Offset: b.offset}
+	}
+	op := &pb.Operation{
+		Database: b.database,
+		Table:    b.table,
+		Operation: &pb.Operation_Select{
+			Select: &pb.SelectOperation{
+				Fields:     cols,
+				Where:      b.where,
+				GroupBy:    b.groupBy,
+				Having:     b.having,
+				OrderBy:    b.orderBy,
+				Pagination: pagination,
+			},
+		},
+	}
+	return b.client.executeSingle(ctx, op)
+}
+
+// Insert 批量插入，rows 的每个 map 代表一行，key 为列名
+func (b *QueryBuilder) Insert(ctx context.Context, rows ...map[string]*pb.TypedValue) (*pb.OperationResult, error) {
+	op := &pb.Operation{
+		Database:  b.database,
+		Table:     b.table,
+		Operation: &pb.Operation_Insert{Insert: &pb.InsertOperation{Rows: toRows(rows)}},
+	}
+	return b.client.executeSingle(ctx, op)
+}
+
+// InsertOrUpdate 编译成 INSERT ... ON DUPLICATE KEY UPDATE，updateCols 里的列在冲突时取本次插入的新值
+func (b *QueryBuilder) InsertOrUpdate(ctx context.Context, rows []map[string]*pb.TypedValue, updateCols []string) (*pb.OperationResult, error) {
+	op := &pb.Operation{
+		Database: b.database,
+		Table:    b.table,
+		Operation: &pb.Operation_Insert{Insert: &pb.InsertOperation{
+			Rows:                toRows(rows),
+			UpsertUpdateColumns: updateCols,
+		}},
+	}
+	return b.client.executeSingle(ctx, op)
+}
+
+// Update 开始一个 UPDATE，setFields 是简单的列赋值；需要 SQL 表达式（如 access_count = access_count + 1）
+// 时改用 RawSet
+func (b *QueryBuilder) Update(setFields map[string]*pb.TypedValue) *UpdateBuilder {
+	return &UpdateBuilder{qb: b, setFields: setFields}
+}
+
+// Delete 开始一个 DELETE，调用 Exec() 前必须先用 Where/WhereRaw 设置条件，防止误删全表
+func (b *QueryBuilder) Delete() *DeleteBuilder {
+	return &DeleteBuilder{qb: b}
+}
+
+// toRows 把 map[string]*pb.TypedValue 的行数据转换为 pb.Row
+func toRows(rows []map[string]*pb.TypedValue) []*pb.Row {
+	pbRows := make([]*pb.Row, len(rows))
+	for i, r := range rows {
+		pbRows[i] = &pb.Row{Fields: r}
+	}
+	return pbRows
+}
+
+// UpdateBuilder 是 QueryBuilder.Update() 返回的子构建器，额外支持 RawSet 追加原始 SET 表达式
+type UpdateBuilder struct {
+	qb        *QueryBuilder
+	setFields map[string]*pb.TypedValue
+	rawSet    string
+	rawParams []*pb.TypedValue
+}
+
+// RawSet 用原始 SQL 表达式代替 setFields，如 "access_count = access_count + 1"
+func (u *UpdateBuilder) RawSet(expr string, params ...*pb.TypedValue) *UpdateBuilder {
+	u.rawSet = expr
+	u.rawParams = params
+	return u
+}
+
+// Exec 执行 UPDATE
+func (u *UpdateBuilder) Exec(ctx context.Context) (*pb.OperationResult, error) {
+	op := &pb.Operation{
+		Database: u.qb.database,
+		Table:    u.qb.table,
+		Operation: &pb.Operation_Update{Update: &pb.UpdateOperation{
+			SetFields:    u.setFields,
+			RawSet:       u.rawSet,
+			RawSetParams: u.rawParams,
+			Where:        u.qb.where,
+		}},
+	}
+	return u.qb.client.executeSingle(ctx, op)
+}
+
+// DeleteBuilder 是 QueryBuilder.Delete() 返回的子构建器
+type DeleteBuilder struct {
+	qb *QueryBuilder
+}
+
+// Exec 执行 DELETE
+func (d *DeleteBuilder) Exec(ctx context.Context) (*pb.OperationResult, error) {
+	op := &pb.Operation{
+		Database:  d.qb.database,
+		Table:     d.qb.table,
+		Operation: &pb.Operation_Delete{Delete: &pb.DeleteOperation{Where: d.qb.where}},
+	}
+	return d.qb.client.executeSingle(ctx, op)
+}
+
+// executeSingle 把单个 pb.Operation 跑在非事务连接上，复用和 ExecuteBatch 相同的 executeXxx 执行器，
+// 失败时把 OperationResult.Error 转换成 Go error，方便链式调用直接判 err
+func (c *MySQLClient) executeSingle(ctx context.Context, op *pb.Operation) (*pb.OperationResult, error) {
+	var res *pb.OperationResult
+	switch op.GetOperation().(type) {
+	case *pb.Operation_Insert:
+		res = c.executeInsert(ctx, c.db, op, 0)
+	case *pb.Operation_Update:
+		res = c.executeUpdate(ctx, c.db, op, 0)
+	case *pb.Operation_Delete:
+		res = c.executeDelete(ctx, c.db, op, 0)
+	case *pb.Operation_Select:
+		res = c.executeSelect(ctx, c.db, op, 0)
+	default:
+		return nil, fmt.Errorf("unknown operation type")
+	}
+	if !res.Success {
+		return res, fmt.Errorf("%s", res.Error)
+	}
+	return res, nil
+}
@@ -0,0 +1,46 @@
+// mysql_ident.go
+package storage
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// identPattern 限定一个普通 SQL 标识符（列名、表名、database 名等）必须是字母/下划线开头，
+// 之后只能跟字母数字下划线或 $，拒绝反引号、点号和其它任何可能拼出额外 SQL 的字符
+var identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+
+// quoteIdent 校验并给一个标识符加反引号，不接受带点号的限定名（那种场景请用 quoteQualifiedIdent
+// 把 database/table 分别校验后再拼接），镜像外部 SB builder 里 WrapSymbol 的做法
+func quoteIdent(ident string) (string, error) {
+	if !identPattern.MatchString(ident) {
+		return "", fmt.Errorf("invalid identifier %q", ident)
+	}
+	return "`" + ident + "`", nil
+}
+
+// quoteQualifiedIdent 把 database、table 两段分别校验、加反引号，再拼成 `db`.`table`
+func quoteQualifiedIdent(database, table string) (string, error) {
+	db, err := quoteIdent(database)
+	if err != nil {
+		return "", fmt.Errorf("invalid database identifier: %w", err)
+	}
+	tbl, err := quoteIdent(table)
+	if err != nil {
+		return "", fmt.Errorf("invalid table identifier: %w", err)
+	}
+	return db + "." + tbl, nil
+}
+
+// quoteIdents 批量校验并加反引号，用于列名列表（SELECT 字段、GROUP BY 等）
+func quoteIdents(idents []string) ([]string, error) {
+	quoted := make([]string, len(idents))
+	for i, ident := range idents {
+		q, err := quoteIdent(ident)
+		if err != nil {
+			return nil, err
+		}
+		quoted[i] = q
+	}
+	return quoted, nil
+}
@@ -0,0 +1,75 @@
+package storage
+
+import "testing"
+
+func TestQuoteIdent(t *testing.T) {
+	cases := []struct {
+		name    string
+		ident   string
+		want    string
+		wantErr bool
+	}{
+		{name: "simple", ident: "user_id", want: "`user_id`"},
+		{name: "leading underscore", ident: "_col", want: "`_col`"},
+		{name: "dollar sign allowed mid-identifier", ident: "a$b", want: "`a$b`"},
+		{name: "empty", ident: "", wantErr: true},
+		{name: "leading digit", ident: "1col", wantErr: true},
+		{name: "backtick escape attempt", ident: "id` = 1 OR `1", wantErr: true},
+		{name: "dot qualified name rejected", ident: "db.table", wantErr: true},
+		{name: "space", ident: "id ", wantErr: true},
+		{name: "comment injection", ident: "id/*", wantErr: true},
+		{name: "stacked statement attempt", ident: "id; DROP TABLE users", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := quoteIdent(tc.ident)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("quoteIdent(%q) = %q, want error", tc.ident, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("quoteIdent(%q) returned unexpected error: %v", tc.ident, err)
+			}
+			if got != tc.want {
+				t.Fatalf("quoteIdent(%q) = %q, want %q", tc.ident, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestQuoteQualifiedIdent(t *testing.T) {
+	got, err := quoteQualifiedIdent("mydb", "users")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "`mydb`.`users`"; got != want {
+		t.Fatalf("quoteQualifiedIdent() = %q, want %q", got, want)
+	}
+
+	if _, err := quoteQualifiedIdent("mydb`; DROP TABLE users; --", "users"); err == nil {
+		t.Fatal("quoteQualifiedIdent() with invalid database should fail")
+	}
+	if _, err := quoteQualifiedIdent("mydb", "users`; DROP TABLE users; --"); err == nil {
+		t.Fatal("quoteQualifiedIdent() with invalid table should fail")
+	}
+}
+
+func TestQuoteIdents(t *testing.T) {
+	got, err := quoteIdents([]string{"id", "name"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"`id`", "`name`"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("quoteIdents() = %v, want %v", got, want)
+		}
+	}
+
+	if _, err := quoteIdents([]string{"id", "name; DROP TABLE users"}); err == nil {
+		t.Fatal("quoteIdents() should fail when any identifier is invalid")
+	}
+}
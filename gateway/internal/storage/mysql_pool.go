@@ -0,0 +1,188 @@
+// mysql_pool.go
+package storage
+
+import (
+	"bot_agent/gateway/internal/logger"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MySQLConfig 是 NewMySQLClientWithConfig 的连接配置，DSN 以外的字段都有零值默认，
+// 对应旧版 NewMySQLClient 的行为：不设连接池上限，只 Ping 一次
+type MySQLConfig struct {
+	DSN string
+
+	MaxOpenConns    int           // <=0 时不设置上限，沿用 database/sql 默认（不限）
+	MaxIdleConns    int           // <=0 时沿用 database/sql 默认（2）
+	ConnMaxLifetime time.Duration // <=0 时连接不过期
+	ConnMaxIdleTime time.Duration // <=0 时空闲连接不过期
+
+	PingTimeout time.Duration // 每次 Ping 的超时，<=0 时默认 5s
+	PingRetries int           // Ping 失败后的重试次数，<=0 时只 Ping 一次、失败直接返回
+}
+
+func (cfg MySQLConfig) withDefaults() MySQLConfig {
+	if cfg.PingTimeout <= 0 {
+		cfg.PingTimeout = 5 * time.Second
+	}
+	return cfg
+}
+
+// opLatencyStats 单个操作类型（insert/update/delete/select）的耗时统计，受 MySQLClient.opStatsMu 保护
+type opLatencyStats struct {
+	count int64
+	sum   time.Duration
+	min   time.Duration
+	max   time.Duration
+}
+
+func (s *opLatencyStats) observe(d time.Duration) {
+	s.count++
+	s.sum += d
+	if s.count == 1 || d < s.min {
+		s.min = d
+	}
+	if d > s.max {
+		s.max = d
+	}
+}
+
+// OpLatency 是 Stats() 暴露的单个操作类型耗时快照
+type OpLatency struct {
+	Count int64
+	Avg   time.Duration
+	Min   time.Duration
+	Max   time.Duration
+}
+
+// MySQLStats 是 Stats() 的返回值：连接池状态 + 按操作类型分类的耗时分布 + 预编译语句缓存命中情况
+type MySQLStats struct {
+	DB        sql.DBStats
+	OpLatency map[string]OpLatency
+
+	// StmtCacheHits/StmtCacheMisses 在未开启 StmtCacheSize 时恒为 0
+	StmtCacheHits   int64
+	StmtCacheMisses int64
+}
+
+// NewMySQLClientWithConfig 按 MySQLConfig 建立连接，支持连接池参数调优和建连时的 Ping 重试退避
+func NewMySQLClientWithConfig(cfg MySQLConfig, opts *MySQLClientOptions) (*MySQLClient, error) {
+	cfg = cfg.withDefaults()
+	if opts == nil {
+		opts = &MySQLClientOptions{}
+	}
+
+	db, err := sql.Open("mysql", cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("sql open error: %w", err)
+	}
+
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+
+	if err := pingWithRetry(db, cfg.PingTimeout, cfg.PingRetries); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sql ping error: %w", err)
+	}
+
+	c := &MySQLClient{
+		db:          db,
+		allowRawSQL: opts.AllowRawSQL,
+		opStats:     make(map[string]*opLatencyStats),
+	}
+	if opts.StmtCacheSize > 0 {
+		c.stmtCache = newStmtCache(opts.StmtCacheSize, opts.StmtCacheTTL)
+	}
+	return c, nil
+}
+
+// pingWithRetry 对 db.Ping 做固定步长退避重试，retries<=0 时只 Ping 一次
+func pingWithRetry(db *sql.DB, timeout time.Duration, retries int) error {
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			backoff := time.Duration(attempt) * 200 * time.Millisecond
+			logger.Warn("MySQL ping failed, retrying in %s (attempt %d/%d): %v", backoff, attempt, retries, err)
+			time.Sleep(backoff)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err = db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// HealthCheck 启动一个后台 goroutine，按 interval 周期性 Ping 数据库并记录失败日志，
+// 返回的 stop 函数用于停止检查；Close 不会自动停止 HealthCheck，调用方需要自己在关闭前调用 stop
+func (c *MySQLClient) HealthCheck(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				err := c.db.PingContext(ctx)
+				cancel()
+				if err != nil {
+					logger.Warn("MySQL health check ping failed: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// Stats 返回当前连接池状态和按操作类型分类的耗时分布快照
+func (c *MySQLClient) Stats() MySQLStats {
+	c.opStatsMu.RLock()
+	defer c.opStatsMu.RUnlock()
+
+	latency := make(map[string]OpLatency, len(c.opStats))
+	for op, s := range c.opStats {
+		var avg time.Duration
+		if s.count > 0 {
+			avg = s.sum / time.Duration(s.count)
+		}
+		latency[op] = OpLatency{Count: s.count, Avg: avg, Min: s.min, Max: s.max}
+	}
+
+	stats := MySQLStats{
+		DB:        c.db.Stats(),
+		OpLatency: latency,
+	}
+	if c.stmtCache != nil {
+		stats.StmtCacheHits, stats.StmtCacheMisses = c.stmtCache.stats()
+	}
+	return stats
+}
+
+// recordOpLatency 记录一次操作耗时，op 取 "insert"/"update"/"delete"/"select"
+func (c *MySQLClient) recordOpLatency(op string, d time.Duration) {
+	c.opStatsMu.Lock()
+	defer c.opStatsMu.Unlock()
+	s := c.opStats[op]
+	if s == nil {
+		s = &opLatencyStats{}
+		c.opStats[op] = s
+	}
+	s.observe(d)
+}
@@ -0,0 +1,382 @@
+// mysql_router.go
+package storage
+
+import (
+	"bot_agent/gateway/internal/logger"
+	"bot_agent/gateway/internal/pb"
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ShardConfig 描述一个分片的写库 + 只读副本连接参数
+type ShardConfig struct {
+	Writer   MySQLConfig
+	Replicas []MySQLConfig
+}
+
+// MySQLRouterConfig 是 NewMySQLRouter 的配置：按分片命名的连接池 + database 到分片的映射
+type MySQLRouterConfig struct {
+	Shards        map[string]ShardConfig // 分片名 -> 写库/副本配置
+	DatabaseShard map[string]string      // database 名 -> 分片名，未命中时落到 DefaultShard
+	DefaultShard  string
+
+	// MaxReplicaLag 超过这个延迟的副本视为过期：PREFER_REPLICA 会退回写库，REPLICA_ONLY 仍然使用
+	// 副本（没有写库可退）。<=0 表示不做新鲜度判断，所有副本都当作新鲜的
+	MaxReplicaLag time.Duration
+	// LagPollInterval 轮询副本 SHOW SLAVE STATUS 的周期，<=0 时不开启后台轮询（MaxReplicaLag 也就不起作用）
+	LagPollInterval time.Duration
+
+	ClientOptions *MySQLClientOptions
+}
+
+// shardPool 持有一个分片的写库连接和一组只读副本，以及副本延迟的探测结果
+type shardPool struct {
+	name     string
+	writer   *MySQLClient
+	replicas []*MySQLClient
+
+	rrCounter uint64 // atomic，副本轮询游标
+
+	lagMu sync.RWMutex
+	lag   map[*MySQLClient]time.Duration // 副本 -> 最近一次探测到的 Seconds_Behind_Master
+}
+
+// pickReplica 从分片的副本里按轮询顺序挑一个：优先挑延迟在 maxLag 以内的，都过期时
+// allowStale 决定是退回 false（上层再去用写库）还是将就用本轮轮询起点处的那个副本（REPLICA_ONLY 场景）
+func (p *shardPool) pickReplica(maxLag time.Duration, allowStale bool) (*MySQLClient, bool) {
+	if len(p.replicas) == 0 {
+		return nil, false
+	}
+
+	n := len(p.replicas)
+	start := int(atomic.AddUint64(&p.rrCounter, 1) % uint64(n))
+
+	p.lagMu.RLock()
+	defer p.lagMu.RUnlock()
+
+	var stale *MySQLClient
+	for i := 0; i < n; i++ {
+		replica := p.replicas[(start+i)%n]
+		lag, known := p.lag[replica]
+		fresh := maxLag <= 0 || !known || lag <= maxLag
+		if fresh {
+			return replica, true
+		}
+		if stale == nil {
+			stale = replica
+		}
+	}
+	if allowStale {
+		return stale, true
+	}
+	return nil, false
+}
+
+// MySQLRouter 按 (a) op.Shard 显式指定、(b) database -> 分片映射、(c) DefaultShard 三级优先级路由
+// 每个 pb.Operation，对 Operation_Select 还会按 ReadPreference 在写库和只读副本之间挑选
+type MySQLRouter struct {
+	mu            sync.RWMutex
+	pools         map[string]*shardPool
+	databaseShard map[string]string
+	defaultShard  string
+	maxReplicaLag time.Duration
+
+	stopLagPoll func()
+}
+
+// NewMySQLRouter 按配置逐个建立分片的写库/副本连接，任意一个建连失败都会回滚已建立的连接并返回错误
+func NewMySQLRouter(cfg MySQLRouterConfig) (*MySQLRouter, error) {
+	if len(cfg.Shards) == 0 {
+		return nil, fmt.Errorf("mysql router: at least one shard is required")
+	}
+	if cfg.DefaultShard == "" {
+		return nil, fmt.Errorf("mysql router: default shard is required")
+	}
+	if _, ok := cfg.Shards[cfg.DefaultShard]; !ok {
+		return nil, fmt.Errorf("mysql router: default shard %q has no config", cfg.DefaultShard)
+	}
+
+	r := &MySQLRouter{
+		pools:         make(map[string]*shardPool, len(cfg.Shards)),
+		databaseShard: cfg.DatabaseShard,
+		defaultShard:  cfg.DefaultShard,
+		maxReplicaLag: cfg.MaxReplicaLag,
+	}
+
+	for name, shard := range cfg.Shards {
+		writer, err := NewMySQLClientWithConfig(shard.Writer, cfg.ClientOptions)
+		if err != nil {
+			r.closeAll()
+			return nil, fmt.Errorf("mysql router: init writer for shard %q: %w", name, err)
+		}
+		pool := &shardPool{name: name, writer: writer, lag: make(map[*MySQLClient]time.Duration)}
+
+		for i, replicaCfg := range shard.Replicas {
+			replica, err := NewMySQLClientWithConfig(replicaCfg, cfg.ClientOptions)
+			if err != nil {
+				writer.Close()
+				for _, opened := range pool.replicas {
+					opened.Close()
+				}
+				r.closeAll()
+				return nil, fmt.Errorf("mysql router: init replica %d for shard %q: %w", i, name, err)
+			}
+			pool.replicas = append(pool.replicas, replica)
+		}
+		r.pools[name] = pool
+	}
+
+	if cfg.LagPollInterval > 0 {
+		r.stopLagPoll = r.startLagPoll(cfg.LagPollInterval)
+	}
+
+	return r, nil
+}
+
+// closeAll 在初始化中途失败时回滚已建立的连接，不收集错误（本来就要整体返回失败）
+func (r *MySQLRouter) closeAll() {
+	for _, pool := range r.pools {
+		pool.writer.Close()
+		for _, replica := range pool.replicas {
+			replica.Close()
+		}
+	}
+}
+
+// resolveShardName 解析一个 Operation 应该落到哪个分片：op.Shard 显式指定优先，
+// 其次按 database 名查表，都没有命中就用 DefaultShard
+func (r *MySQLRouter) resolveShardName(op *pb.Operation) string {
+	if shard := op.GetShard(); shard != "" {
+		return shard
+	}
+	if r.databaseShard != nil {
+		if shard, ok := r.databaseShard[op.GetDatabase()]; ok {
+			return shard
+		}
+	}
+	return r.defaultShard
+}
+
+func (r *MySQLRouter) resolvePool(shardName string) (*shardPool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	pool, ok := r.pools[shardName]
+	if !ok {
+		return nil, fmt.Errorf("mysql router: unknown shard %q", shardName)
+	}
+	return pool, nil
+}
+
+// resolveClient 决定一个非事务 Operation 具体打到哪个 *MySQLClient：写操作永远走写库，
+// Select 按 ReadPreference（默认 PRIMARY）决定是否尝试副本
+func (r *MySQLRouter) resolveClient(op *pb.Operation) (*MySQLClient, error) {
+	pool, err := r.resolvePool(r.resolveShardName(op))
+	if err != nil {
+		return nil, err
+	}
+
+	selectOp, isSelect := op.GetOperation().(*pb.Operation_Select)
+	if !isSelect {
+		return pool.writer, nil
+	}
+
+	switch selectOp.Select.GetReadPreference() {
+	case pb.ReadPreference_REPLICA_ONLY:
+		replica, ok := pool.pickReplica(r.maxReplicaLag, true)
+		if !ok {
+			return nil, fmt.Errorf("mysql router: shard %q has no replica for REPLICA_ONLY read", pool.name)
+		}
+		return replica, nil
+	case pb.ReadPreference_PREFER_REPLICA:
+		if replica, ok := pool.pickReplica(r.maxReplicaLag, false); ok {
+			return replica, nil
+		}
+		return pool.writer, nil
+	default: // PRIMARY 或未设置
+		return pool.writer, nil
+	}
+}
+
+// resolvePoolForTx 要求整批操作落在同一个分片上，事务不能跨分片
+func (r *MySQLRouter) resolvePoolForTx(ops []*pb.Operation) (*shardPool, error) {
+	shardName := r.resolveShardName(ops[0])
+	for _, op := range ops[1:] {
+		if name := r.resolveShardName(op); name != shardName {
+			return nil, fmt.Errorf("mysql router: transaction spans multiple shards (%q and %q), not supported", shardName, name)
+		}
+	}
+	return r.resolvePool(shardName)
+}
+
+// ExecuteBatch 和 MySQLClient.ExecuteBatch 签名一致：事务整批钉在一个分片的写库上执行；
+// 非事务时按 op 逐个路由（写操作走写库，Select 按 ReadPreference 可能落到副本），
+// 再按目标连接分组批量执行，最后按原始下标回填结果，保持调用方看到的顺序不变
+func (r *MySQLRouter) ExecuteBatch(ctx context.Context, ops []*pb.Operation, useTx bool) ([]*pb.OperationResult, error) {
+	if len(ops) == 0 {
+		return nil, nil
+	}
+
+	if useTx {
+		pool, err := r.resolvePoolForTx(ops)
+		if err != nil {
+			return nil, err
+		}
+		return pool.writer.ExecuteBatch(ctx, ops, true)
+	}
+
+	type group struct {
+		client  *MySQLClient
+		indexes []int
+	}
+	groups := make(map[*MySQLClient]*group)
+	var order []*MySQLClient
+
+	for i, op := range ops {
+		client, err := r.resolveClient(op)
+		if err != nil {
+			return nil, err
+		}
+		g, ok := groups[client]
+		if !ok {
+			g = &group{client: client}
+			groups[client] = g
+			order = append(order, client)
+		}
+		g.indexes = append(g.indexes, i)
+	}
+
+	results := make([]*pb.OperationResult, len(ops))
+	for _, client := range order {
+		g := groups[client]
+		subOps := make([]*pb.Operation, len(g.indexes))
+		for j, idx := range g.indexes {
+			subOps[j] = ops[idx]
+		}
+
+		subResults, err := client.ExecuteBatch(ctx, subOps, false)
+		if err != nil {
+			return nil, err
+		}
+		for j, idx := range g.indexes {
+			res := subResults[j]
+			res.Index = int32(idx) // 还原成原始 batch 里的下标
+			results[idx] = res
+		}
+	}
+	return results, nil
+}
+
+// Close 停止副本延迟轮询并关闭所有分片的写库/副本连接
+func (r *MySQLRouter) Close() error {
+	if r.stopLagPoll != nil {
+		r.stopLagPoll()
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var errs []string
+	for name, pool := range r.pools {
+		if err := pool.writer.Close(); err != nil {
+			errs = append(errs, fmt.Sprintf("shard %q writer: %v", name, err))
+		}
+		for i, replica := range pool.replicas {
+			if err := replica.Close(); err != nil {
+				errs = append(errs, fmt.Sprintf("shard %q replica %d: %v", name, i, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("mysql router close errors: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// startLagPoll 启动后台 goroutine，按 interval 周期性探测所有分片副本的复制延迟
+func (r *MySQLRouter) startLagPoll(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.pollReplicaLag()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// pollReplicaLag 对每个分片的每个副本执行一次 SHOW SLAVE STATUS，更新 shardPool.lag
+func (r *MySQLRouter) pollReplicaLag() {
+	r.mu.RLock()
+	pools := make([]*shardPool, 0, len(r.pools))
+	for _, p := range r.pools {
+		pools = append(pools, p)
+	}
+	r.mu.RUnlock()
+
+	for _, pool := range pools {
+		for _, replica := range pool.replicas {
+			lag, err := queryReplicationLag(replica.db)
+			if err != nil {
+				logger.Warn("mysql router: poll replica lag for shard %q failed: %v", pool.name, err)
+				continue
+			}
+			pool.lagMu.Lock()
+			pool.lag[replica] = lag
+			pool.lagMu.Unlock()
+		}
+	}
+}
+
+// queryReplicationLag 执行 SHOW SLAVE STATUS 并解析 Seconds_Behind_Master 列，按列名大小写不敏感匹配，
+// 兼容 MySQL/MariaDB 之间的列名差异
+func queryReplicationLag(db *sql.DB) (time.Duration, error) {
+	rows, err := db.QueryContext(context.Background(), "SHOW SLAVE STATUS")
+	if err != nil {
+		return 0, fmt.Errorf("show slave status error: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, fmt.Errorf("get columns error: %w", err)
+	}
+	if !rows.Next() {
+		return 0, fmt.Errorf("show slave status returned no rows (not a replica?)")
+	}
+
+	scanDest := make([]interface{}, len(columns))
+	for i := range scanDest {
+		scanDest[i] = new(sql.RawBytes)
+	}
+	if err := rows.Scan(scanDest...); err != nil {
+		return 0, fmt.Errorf("scan slave status error: %w", err)
+	}
+
+	for i, col := range columns {
+		if !strings.EqualFold(col, "Seconds_Behind_Master") {
+			continue
+		}
+		raw := *(scanDest[i].(*sql.RawBytes))
+		if len(raw) == 0 {
+			return 0, fmt.Errorf("replica has no Seconds_Behind_Master value (replication stopped?)")
+		}
+		seconds, err := strconv.Atoi(string(raw))
+		if err != nil {
+			return 0, fmt.Errorf("parse Seconds_Behind_Master error: %w", err)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, fmt.Errorf("show slave status: Seconds_Behind_Master column not found")
+}
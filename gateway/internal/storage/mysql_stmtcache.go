@@ -0,0 +1,132 @@
+// mysql_stmtcache.go
+package storage
+
+import (
+	"bot_agent/gateway/internal/pb"
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// stmtCacheEntry 是 LRU 链表节点对应的缓存项
+type stmtCacheEntry struct {
+	key       string
+	stmt      *sql.Stmt
+	expiresAt time.Time // 零值表示永不过期
+}
+
+// stmtCache 是一个按渲染后的 SQL 模板（? 占位符，绑参前）为 key 的 LRU + TTL 预编译语句缓存，
+// 只缓存针对 *sql.DB Prepare 出来的 Stmt——事务内绑定 *sql.Tx 的语句不能跨请求复用，见 isCacheable
+type stmtCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	ll       *list.List               // 最近使用的在链表前端
+	items    map[string]*list.Element // key -> *list.Element，Element.Value 是 *stmtCacheEntry
+
+	hits   int64 // atomic
+	misses int64 // atomic
+}
+
+func newStmtCache(capacity int, ttl time.Duration) *stmtCache {
+	return &stmtCache{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// getOrPrepare 命中且未过期直接返回缓存的 Stmt 并提到链表前端；否则现场 Prepare 并放入缓存，
+// 必要时淘汰最久未用的项腾出空间
+func (c *stmtCache) getOrPrepare(ctx context.Context, db *sql.DB, query string) (*sql.Stmt, error) {
+	c.mu.Lock()
+	if el, ok := c.items[query]; ok {
+		entry := el.Value.(*stmtCacheEntry)
+		if entry.expiresAt.IsZero() || time.Now().Before(entry.expiresAt) {
+			c.ll.MoveToFront(el)
+			c.mu.Unlock()
+			atomic.AddInt64(&c.hits, 1)
+			return entry.stmt, nil
+		}
+		// 已过期，先摘除再走下面重新 Prepare
+		c.ll.Remove(el)
+		delete(c.items, query)
+		entry.stmt.Close()
+	}
+	c.mu.Unlock()
+
+	atomic.AddInt64(&c.misses, 1)
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 双重检查：Prepare 期间可能有并发请求已经抢先放入了同一个 key
+	if el, ok := c.items[query]; ok {
+		c.ll.MoveToFront(el)
+		stmt.Close()
+		return el.Value.(*stmtCacheEntry).stmt, nil
+	}
+
+	var expiresAt time.Time
+	if c.ttl > 0 {
+		expiresAt = time.Now().Add(c.ttl)
+	}
+	el := c.ll.PushFront(&stmtCacheEntry{key: query, stmt: stmt, expiresAt: expiresAt})
+	c.items[query] = el
+
+	if c.capacity > 0 {
+		for c.ll.Len() > c.capacity {
+			c.evictOldestLocked()
+		}
+	}
+	return stmt, nil
+}
+
+// evictOldestLocked 淘汰链表尾部（最久未用）的一项，调用方必须已持有 c.mu
+func (c *stmtCache) evictOldestLocked() {
+	el := c.ll.Back()
+	if el == nil {
+		return
+	}
+	c.ll.Remove(el)
+	entry := el.Value.(*stmtCacheEntry)
+	delete(c.items, entry.key)
+	entry.stmt.Close()
+}
+
+// stats 返回命中/未命中计数快照，供 MySQLClient.Stats() 暴露
+func (c *stmtCache) stats() (hits, misses int64) {
+	return atomic.LoadInt64(&c.hits), atomic.LoadInt64(&c.misses)
+}
+
+// close 关闭缓存里所有的 Stmt，MySQLClient.Close() 时调用
+func (c *stmtCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, el := range c.items {
+		el.Value.(*stmtCacheEntry).stmt.Close()
+	}
+	c.items = make(map[string]*list.Element)
+	c.ll.Init()
+}
+
+// isCacheable 判断一次 Exec/Query 能不能走预编译语句缓存：事务内的 *sql.Tx 不行，
+// 因为 Stmt 一旦绑定到某个 Tx 就不能被其它请求复用
+func isCacheable(exec executor) bool {
+	_, isTx := exec.(*sql.Tx)
+	return !isTx
+}
+
+// usesRawClause 判断一个 WhereClause 是否用了 raw_clause（RawSet 由调用方直接判断 RawSet 字段）。
+// raw_clause/raw_set 拼出的 SQL 片段形状不固定，同一个字段组合下次调用可能渲染出完全不同的文本，
+// 缓存意义不大，干脆跳过，避免缓存里塞满只会命中一次的模板
+func usesRawClause(where *pb.WhereClause) bool {
+	return where != nil && where.RawClause != ""
+}
@@ -0,0 +1,112 @@
+// mysql_stream.go
+package storage
+
+import (
+	"bot_agent/gateway/internal/pb"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// defaultStreamBatchSize 是 SelectStream 在调用方传入 batchSize<=0 时使用的默认批大小
+const defaultStreamBatchSize = 100
+
+// SelectStream 流式执行 SELECT，不在内存里攒完整结果集：按 batchSize 为单位检查一次 ctx 是否取消，
+// 逐行把 ResultRow 推进 rowCh，供 StorageService.ExecuteSelectStream 转发成 gRPC server-streaming 响应。
+// rowCh 在查询结束（正常或出错）时关闭；errCh 最多发送一个错误，调用方应该先排空 rowCh 再读 errCh
+func (c *MySQLClient) SelectStream(ctx context.Context, op *pb.Operation, batchSize int) (<-chan *pb.ResultRow, <-chan error) {
+	if batchSize <= 0 {
+		batchSize = defaultStreamBatchSize
+	}
+
+	rowCh := make(chan *pb.ResultRow, batchSize)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(rowCh)
+		defer close(errCh)
+
+		database := op.GetDatabase()
+		table := op.GetTable()
+		selectOp := op.GetSelect()
+		if database == "" || table == "" {
+			errCh <- fmt.Errorf("select stream: database/table is required")
+			return
+		}
+		if selectOp == nil {
+			errCh <- fmt.Errorf("select stream: select operation is nil")
+			return
+		}
+
+		query, queryParams, err := c.buildSelectQuery(database, table, selectOp)
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		rows, err := c.db.QueryContext(ctx, query, queryParams...)
+		if err != nil {
+			errCh <- fmt.Errorf("select stream query error: %w", err)
+			return
+		}
+		// ctx 取消时及时 Close，不把整个结果集拉完才释放连接
+		defer rows.Close()
+
+		columns, err := rows.Columns()
+		if err != nil {
+			errCh <- fmt.Errorf("get columns error: %w", err)
+			return
+		}
+		columnTypes, err := rows.ColumnTypes()
+		if err != nil {
+			errCh <- fmt.Errorf("get column types error: %w", err)
+			return
+		}
+
+		sent := 0
+		for rows.Next() {
+			// 每攒够一批就检查一次 ctx，避免取消后还继续白白扫描剩下的行
+			if sent%batchSize == 0 {
+				select {
+				case <-ctx.Done():
+					errCh <- ctx.Err()
+					return
+				default:
+				}
+			}
+
+			scanDest := make([]interface{}, len(columns))
+			for i := range scanDest {
+				scanDest[i] = new(interface{})
+			}
+			if err := rows.Scan(scanDest...); err != nil {
+				errCh <- fmt.Errorf("scan row error: %w", err)
+				return
+			}
+
+			rowFields := make(map[string]*pb.TypedValue, len(columns))
+			for i, col := range columns {
+				val := *(scanDest[i].(*interface{}))
+				var colType *sql.ColumnType
+				if columnTypes != nil && i < len(columnTypes) {
+					colType = columnTypes[i]
+				}
+				rowFields[col] = convertToTypedValue(val, colType)
+			}
+
+			select {
+			case rowCh <- &pb.ResultRow{Fields: rowFields}:
+				sent++
+			case <-ctx.Done():
+				errCh <- ctx.Err()
+				return
+			}
+		}
+
+		if err := rows.Err(); err != nil {
+			errCh <- fmt.Errorf("rows iteration error: %w", err)
+		}
+	}()
+
+	return rowCh, errCh
+}
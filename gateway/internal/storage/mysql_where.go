@@ -0,0 +1,116 @@
+// mysql_where.go
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	pb "bot_agent/gateway/internal/pb"
+)
+
+// compileWhereNode 把结构化的 WhereNode DSL 编译成带 "?" 占位符的 SQL 片段，递归处理
+// AND/OR/NOT 复合节点和富操作符叶子条件，返回的参数和占位符按出现顺序一一对应，调用方
+// 原样 append 到已有的 values 列表即可
+func compileWhereNode(node *pb.WhereNode) (string, []interface{}, error) {
+	if node == nil {
+		return "", nil, fmt.Errorf("where node is nil")
+	}
+	switch n := node.GetNode().(type) {
+	case *pb.WhereNode_And:
+		return compileWhereGroup(n.And, " AND ")
+	case *pb.WhereNode_Or:
+		return compileWhereGroup(n.Or, " OR ")
+	case *pb.WhereNode_Not:
+		expr, params, err := compileWhereNode(n.Not)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("NOT (%s)", expr), params, nil
+	case *pb.WhereNode_Cond:
+		return compileWhereCondition(n.Cond)
+	default:
+		return "", nil, fmt.Errorf("where node has no and/or/not/cond set")
+	}
+}
+
+// compileWhereGroup 编译 AND/OR 复合节点，每个子节点的表达式都加括号，避免和外层运算符
+// 混在一起造成优先级歧义
+func compileWhereGroup(group *pb.WhereGroup, sep string) (string, []interface{}, error) {
+	if group == nil || len(group.Nodes) == 0 {
+		return "", nil, fmt.Errorf("where group requires at least one child node")
+	}
+	parts := make([]string, 0, len(group.Nodes))
+	var params []interface{}
+	for _, sub := range group.Nodes {
+		expr, subParams, err := compileWhereNode(sub)
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, fmt.Sprintf("(%s)", expr))
+		params = append(params, subParams...)
+	}
+	return strings.Join(parts, sep), params, nil
+}
+
+// compileWhereCondition 编译单个叶子条件，字段名先经 quoteIdent 校验并加反引号，
+// 拒绝任何不是合法标识符的 Field（防止借 Field 拼出额外 SQL）
+func compileWhereCondition(cond *pb.WhereCondition) (string, []interface{}, error) {
+	if cond == nil {
+		return "", nil, fmt.Errorf("where condition is nil")
+	}
+	if cond.Field == "" {
+		return "", nil, fmt.Errorf("where condition field is required")
+	}
+	field, err := quoteIdent(cond.Field)
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch cond.Op {
+	case pb.WhereOp_EQ:
+		return fmt.Sprintf("%s = ?", field), []interface{}{extractTypedValue(cond.Value)}, nil
+	case pb.WhereOp_NE:
+		return fmt.Sprintf("%s != ?", field), []interface{}{extractTypedValue(cond.Value)}, nil
+	case pb.WhereOp_LT:
+		return fmt.Sprintf("%s < ?", field), []interface{}{extractTypedValue(cond.Value)}, nil
+	case pb.WhereOp_LTE:
+		return fmt.Sprintf("%s <= ?", field), []interface{}{extractTypedValue(cond.Value)}, nil
+	case pb.WhereOp_GT:
+		return fmt.Sprintf("%s > ?", field), []interface{}{extractTypedValue(cond.Value)}, nil
+	case pb.WhereOp_GTE:
+		return fmt.Sprintf("%s >= ?", field), []interface{}{extractTypedValue(cond.Value)}, nil
+	case pb.WhereOp_LIKE:
+		return fmt.Sprintf("%s LIKE ?", field), []interface{}{extractTypedValue(cond.Value)}, nil
+
+	case pb.WhereOp_IN, pb.WhereOp_NOT_IN:
+		if len(cond.Values) == 0 {
+			return "", nil, fmt.Errorf("where condition %q: IN/NOT_IN requires at least one value", cond.Field)
+		}
+		placeholders := make([]string, len(cond.Values))
+		params := make([]interface{}, len(cond.Values))
+		for i, v := range cond.Values {
+			placeholders[i] = "?"
+			params[i] = extractTypedValue(v)
+		}
+		op := "IN"
+		if cond.Op == pb.WhereOp_NOT_IN {
+			op = "NOT IN"
+		}
+		return fmt.Sprintf("%s %s (%s)", field, op, strings.Join(placeholders, ", ")), params, nil
+
+	case pb.WhereOp_BETWEEN:
+		if len(cond.Values) != 2 {
+			return "", nil, fmt.Errorf("where condition %q: BETWEEN requires exactly two values", cond.Field)
+		}
+		return fmt.Sprintf("%s BETWEEN ? AND ?", field),
+			[]interface{}{extractTypedValue(cond.Values[0]), extractTypedValue(cond.Values[1])}, nil
+
+	case pb.WhereOp_IS_NULL:
+		return fmt.Sprintf("%s IS NULL", field), nil, nil
+	case pb.WhereOp_IS_NOT_NULL:
+		return fmt.Sprintf("%s IS NOT NULL", field), nil, nil
+
+	default:
+		return "", nil, fmt.Errorf("where condition %q: unsupported operator %v", cond.Field, cond.Op)
+	}
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+
+	pb "bot_agent/gateway/internal/pb"
+)
+
+func stringCond(field string, op pb.WhereOp, value string) *pb.WhereCondition {
+	return &pb.WhereCondition{
+		Field: field,
+		Op:    op,
+		Value: &pb.TypedValue{Value: &pb.TypedValue_StringValue{StringValue: value}},
+	}
+}
+
+func TestCompileWhereCondition_RejectsInjectionInField(t *testing.T) {
+	payloads := []string{
+		"id; DROP TABLE users",
+		"id` = 1 OR `1` = `1",
+		"id -- ",
+		"id/*comment*/",
+		"",
+	}
+	for _, field := range payloads {
+		t.Run(field, func(t *testing.T) {
+			cond := stringCond(field, pb.WhereOp_EQ, "x")
+			if _, _, err := compileWhereCondition(cond); err == nil {
+				t.Fatalf("compileWhereCondition with field %q should have been rejected", field)
+			}
+		})
+	}
+}
+
+func TestCompileWhereCondition_ValueGoesThroughPlaceholder(t *testing.T) {
+	// 哪怕 value 里带着看起来像 SQL 的内容，也必须原样作为参数通过占位符传递，
+	// 不能被拼进 SQL 文本
+	injection := "x' OR '1'='1"
+	cond := stringCond("name", pb.WhereOp_EQ, injection)
+
+	expr, params, err := compileWhereCondition(cond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(expr, injection) {
+		t.Fatalf("compiled expression %q must not embed the raw value", expr)
+	}
+	if expr != "`name` = ?" {
+		t.Fatalf("compileWhereCondition() expr = %q, want `name` = ?", expr)
+	}
+	if len(params) != 1 || params[0] != injection {
+		t.Fatalf("compileWhereCondition() params = %v, want [%q]", params, injection)
+	}
+}
+
+func TestCompileWhereCondition_InRequiresValues(t *testing.T) {
+	cond := &pb.WhereCondition{Field: "id", Op: pb.WhereOp_IN}
+	if _, _, err := compileWhereCondition(cond); err == nil {
+		t.Fatal("IN with no values should be rejected")
+	}
+}
+
+func TestCompileWhereCondition_BetweenRequiresTwoValues(t *testing.T) {
+	cond := &pb.WhereCondition{
+		Field: "id",
+		Op:    pb.WhereOp_BETWEEN,
+		Values: []*pb.TypedValue{
+			{Value: &pb.TypedValue_IntValue{IntValue: 1}},
+		},
+	}
+	if _, _, err := compileWhereCondition(cond); err == nil {
+		t.Fatal("BETWEEN with one value should be rejected")
+	}
+}
+
+func TestCompileWhereCondition_IsNullHasNoParams(t *testing.T) {
+	cond := &pb.WhereCondition{Field: "deleted_at", Op: pb.WhereOp_IS_NULL}
+	expr, params, err := compileWhereCondition(cond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expr != "`deleted_at` IS NULL" || len(params) != 0 {
+		t.Fatalf("compileWhereCondition() = (%q, %v), want (`deleted_at` IS NULL, [])", expr, params)
+	}
+}
+
+func TestCompileWhereNode_AndGroupRejectsBadField(t *testing.T) {
+	node := &pb.WhereNode{
+		Node: &pb.WhereNode_And{
+			And: &pb.WhereGroup{
+				Nodes: []*pb.WhereNode{
+					{Node: &pb.WhereNode_Cond{Cond: stringCond("id", pb.WhereOp_EQ, "1")}},
+					{Node: &pb.WhereNode_Cond{Cond: stringCond("id; DROP TABLE users", pb.WhereOp_EQ, "1")}},
+				},
+			},
+		},
+	}
+	if _, _, err := compileWhereNode(node); err == nil {
+		t.Fatal("compileWhereNode should propagate the invalid-field error from a nested condition")
+	}
+}
@@ -0,0 +1,52 @@
+// quota.go
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"bot_agent/gateway/internal/quota"
+)
+
+// quotaUsageTable 是配额用量落地的 MySQL 表，需提前在目标库建好：
+//
+//	CREATE TABLE quota_usage (
+//	  `key` VARCHAR(128) NOT NULL,
+//	  model VARCHAR(128) NOT NULL,
+//	  period_month CHAR(7) NOT NULL, -- 如 2026-07
+//	  prompt_tokens BIGINT NOT NULL DEFAULT 0,
+//	  completion_tokens BIGINT NOT NULL DEFAULT 0,
+//	  cost_usd DOUBLE NOT NULL DEFAULT 0,
+//	  PRIMARY KEY (`key`, model, period_month)
+//	);
+const quotaUsageTable = "quota_usage"
+
+// FlushUsage 实现 quota.Store：把内存中累积的配额用量批量 upsert 到 quota_usage 表
+// 使用 ON DUPLICATE KEY UPDATE 按 (key, model, period_month) 累加，重启或多次 flush 不会重复计费
+func (c *MySQLClient) FlushUsage(ctx context.Context, records []quota.UsageRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	rowPlaceholder := "(?, ?, ?, ?, ?, ?)"
+	placeholders := make([]string, len(records))
+	values := make([]interface{}, 0, len(records)*6)
+
+	for i, r := range records {
+		placeholders[i] = rowPlaceholder
+		values = append(values, r.Key, r.Model, r.RecordedAt.Format("2006-01"), r.PromptTokens, r.CompletionTokens, r.CostUSD)
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (`key`, model, period_month, prompt_tokens, completion_tokens, cost_usd) VALUES %s "+
+			"ON DUPLICATE KEY UPDATE prompt_tokens = prompt_tokens + VALUES(prompt_tokens), "+
+			"completion_tokens = completion_tokens + VALUES(completion_tokens), "+
+			"cost_usd = cost_usd + VALUES(cost_usd)",
+		quotaUsageTable, strings.Join(placeholders, ", "))
+
+	if _, err := c.db.ExecContext(ctx, query, values...); err != nil {
+		return fmt.Errorf("flush quota usage error: %w", err)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"bot_agent/gateway/internal/logger"
+	"bot_agent/gateway/internal/observability"
 	"bot_agent/gateway/internal/pb"
 	"context"
 	"errors"
@@ -31,7 +32,10 @@ func (s *StorageService) SetMilvusClient(client *MilvusClient) {
 }
 
 func (s *StorageService) Execute(ctx context.Context, req *pb.ExecuteRequest) (*pb.ExecuteResponse, error) {
-	logger.Info("Execute req: %v", req)
+	ctx, span := observability.StartSpan(ctx, "StorageService.Execute")
+	defer span.End()
+
+	logger.WithContext(ctx).With(logger.String("grpc_method", "Execute")).Infow("execute request", "operations", len(req.Operations))
 
 	// 检查 MySQL 客户端是否已初始化
 	if s.mysqlClient == nil {
@@ -43,6 +47,7 @@ func (s *StorageService) Execute(ctx context.Context, req *pb.ExecuteRequest) (*
 
 	results, err := s.mysqlClient.ExecuteBatch(ctx, req.Operations, req.UseTransaction)
 	if err != nil {
+		observability.RecordBatch("mysql", "execute", len(req.Operations), len(req.Operations))
 		return &pb.ExecuteResponse{
 			Success: false,
 			Error:   err.Error(),
@@ -58,6 +63,7 @@ func (s *StorageService) Execute(ctx context.Context, req *pb.ExecuteRequest) (*
 			failedErrors = append(failedErrors, res.Error)
 		}
 	}
+	observability.RecordBatch("mysql", "execute", len(req.Operations), len(failedIndices))
 
 	// 如果有失败的操作，汇总错误信息
 	if len(failedIndices) > 0 {
@@ -86,7 +92,10 @@ func (s *StorageService) Execute(ctx context.Context, req *pb.ExecuteRequest) (*
 }
 
 func (s *StorageService) ExecuteVector(ctx context.Context, req *pb.ExecuteVectorRequest) (*pb.ExecuteVectorResponse, error) {
-	logger.Info("ExecuteVector req: %v", req)
+	ctx, span := observability.StartSpan(ctx, "StorageService.ExecuteVector")
+	defer span.End()
+
+	logger.WithContext(ctx).With(logger.String("grpc_method", "ExecuteVector")).Infow("execute vector request", "operations", len(req.Operations))
 
 	// 检查 Milvus 客户端是否已初始化
 	if s.milvusClient == nil {
@@ -99,6 +108,7 @@ func (s *StorageService) ExecuteVector(ctx context.Context, req *pb.ExecuteVecto
 	// 执行向量操作
 	results, err := s.milvusClient.ExecuteBatch(ctx, req.Operations)
 	if err != nil {
+		observability.RecordBatch("milvus", "execute_vector", len(req.Operations), len(req.Operations))
 		return &pb.ExecuteVectorResponse{
 			Success: false,
 			Error:   err.Error(),
@@ -114,6 +124,7 @@ func (s *StorageService) ExecuteVector(ctx context.Context, req *pb.ExecuteVecto
 			failedErrors = append(failedErrors, res.Error)
 		}
 	}
+	observability.RecordBatch("milvus", "execute_vector", len(req.Operations), len(failedIndices))
 
 	// 如果有失败的操作，汇总错误信息
 	if len(failedIndices) > 0 {
@@ -141,6 +152,35 @@ func (s *StorageService) ExecuteVector(ctx context.Context, req *pb.ExecuteVecto
 	}, nil
 }
 
+// ExecuteSelectStream 是 Execute 的流式版本，逐行把 SELECT 结果通过 gRPC server-streaming 推给调用方，
+// 避免大结果集在网关内存里攒成一个巨大的 SelectResult
+func (s *StorageService) ExecuteSelectStream(op *pb.Operation, stream pb.StorageService_ExecuteSelectStreamServer) error {
+	ctx, span := observability.StartSpan(stream.Context(), "StorageService.ExecuteSelectStream")
+	defer span.End()
+
+	logger.WithContext(ctx).With(logger.String("grpc_method", "ExecuteSelectStream")).Infow("execute select stream request", "table", op.GetTable())
+
+	if s.mysqlClient == nil {
+		return fmt.Errorf("MySQL client not initialized")
+	}
+
+	rowCh, errCh := s.mysqlClient.SelectStream(ctx, op, defaultStreamBatchSize)
+
+	var rowCount int
+	for row := range rowCh {
+		if err := stream.Send(row); err != nil {
+			return fmt.Errorf("send result row error: %w", err)
+		}
+		rowCount++
+	}
+	observability.RecordBatch("mysql", "execute_select_stream", rowCount, 0)
+
+	if err := <-errCh; err != nil {
+		return err
+	}
+	return nil
+}
+
 func (s *StorageService) Close() error {
 	var errs []string
 